@@ -0,0 +1,48 @@
+// Parsing shared by the flag-approval workflow, kept here so it can be tested
+// (node --test .github/scripts).
+
+// Only bare entries approve - `readers` and temporary (`team:`/`expires:`)
+// grants don't. Returns null when content has no `role` list.
+const roleTeams = (content, role) => {
+  const block = content.match(new RegExp(`^${role}:[ \\t]*\\n((?:[ \\t]+.*(?:\\n|$))*)`, 'm'))
+  return block && [...block[1].matchAll(/^[ \t]+-[ \t]+([^\s:]+)[ \t]*$/gm)].map((m) => m[1])
+}
+
+// access.yml is canonical-formatted (enforced by make flags-lint), so a light
+// regex parse is safe. contents are the shared flags/access/{namespace}.yml
+// then the prod access.yml, with keys set in a later file taking precedence.
+// Namespaces with neither (e.g. `default`) fall back to admin ownership.
+const readAccess = (contents, adminTeam) => {
+  let writers = []
+  let togglers = []
+  let selfService = false
+
+  for (const content of contents) {
+    writers = roleTeams(content, 'writers') ?? writers
+    togglers = roleTeams(content, 'togglers') ?? togglers
+
+    const selfServiceMatch = content.match(/^prodSelfService:\s*(\S+)\s*$/m)
+    if (selfServiceMatch) {
+      selfService = selfServiceMatch[1] === 'true'
+    }
+  }
+
+  return {
+    writers: writers.length > 0 ? writers : [adminTeam],
+    togglers,
+    selfService,
+  }
+}
+
+// A toggle is a change to a PR file (from pulls.listFiles) whose every added
+// or removed line is a flag's `enabled` value. GitHub leaves out the patch of
+// very large diffs, which never count.
+const onlyToggles = (file) =>
+  file.status === 'modified' &&
+  file.patch !== undefined &&
+  file.patch
+    .split('\n')
+    .filter((line) => /^[+-]/.test(line))
+    .every((line) => /^[+-][ \t]*enabled:[ \t]*(true|false)[ \t]*$/.test(line))
+
+module.exports = { readAccess, onlyToggles }
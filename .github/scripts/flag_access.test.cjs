@@ -0,0 +1,37 @@
+const assert = require('node:assert/strict')
+const fs = require('node:fs')
+const path = require('node:path')
+const { test } = require('node:test')
+
+const { readAccess, onlyToggles } = require('./flag_access.cjs')
+
+const fixture = (name) => fs.readFileSync(path.join(__dirname, 'fixtures', name), 'utf8')
+
+test('writers mixing bare and temporary entries', () => {
+  const access = readAccess([fixture('mixed-writers.yml')], 'admins')
+
+  assert.deepEqual(access.writers, ['hmpps-probation-team', 'hmpps-probation-leads'])
+  assert.deepEqual(access.togglers, ['hmpps-probation-ops'])
+  assert.equal(access.selfService, false)
+})
+
+test('prod access.yml overrides the shared file', () => {
+  const access = readAccess([fixture('mixed-writers.yml'), 'writers:\n  - hmpps-prod-team\nprodSelfService: true\n'], 'admins')
+
+  assert.deepEqual(access.writers, ['hmpps-prod-team'])
+  assert.deepEqual(access.togglers, ['hmpps-probation-ops'])
+  assert.equal(access.selfService, true)
+})
+
+test('no writers falls back to the admin team', () => {
+  assert.deepEqual(readAccess([], 'admins').writers, ['admins'])
+})
+
+test('only toggles', () => {
+  const patch = (...lines) => ['@@ -1,4 +1,4 @@', '   - key: a', ...lines].join('\n')
+
+  assert.equal(onlyToggles({ status: 'modified', patch: patch('-    enabled: false', '+    enabled: true') }), true)
+  assert.equal(onlyToggles({ status: 'modified', patch: patch('-    name: A', '+    name: B') }), false)
+  assert.equal(onlyToggles({ status: 'added', patch: patch('+    enabled: true') }), false)
+  assert.equal(onlyToggles({ status: 'modified' }), false)
+})
@@ -7,11 +7,33 @@ default acl_by_environment := {}
 
 acl_by_environment := data.namespace_team_access # regal ignore: unresolved-reference
 
+default roles_by_environment := {}
+
+roles_by_environment := data.namespace_role_access # regal ignore: unresolved-reference
+
+# ACLs read from each branch environment's own files, keyed by branch name.
+default branch_acl_by_environment := {}
+
+branch_acl_by_environment := data.branch_team_access # regal ignore: unresolved-reference
+
+default branch_roles_by_environment := {}
+
+branch_roles_by_environment := data.branch_role_access # regal ignore: unresolved-reference
+
+# Repo-wide settings (admin teams, environment aliases, read-only environments)
+# come from flags/acl-config.yml via the generated data, not constants here.
+default authz_config := {}
+
+authz_config := data.authz_config # regal ignore: unresolved-reference
+
+admin_teams := object.get(authz_config, "admin_teams", [])
+
+environment_aliases := object.get(authz_config, "environment_aliases", {})
+
 default configured_default_environment := ""
 
-# regal ignore: line-length
-configured_default_environment := canonical_environment(data.authz_config.default_environment) if { # regal ignore: unresolved-reference
-	data.authz_config.default_environment # regal ignore: unresolved-reference
+configured_default_environment := canonical_environment(authz_config.default_environment) if {
+	authz_config.default_environment
 }
 
 auth_metadata := object.get(input.authentication, "metadata", {})
@@ -21,12 +43,8 @@ teams_json := object.get(auth_metadata, "io.flipt.auth.github.teams", object.get
 org_teams := json.unmarshal(teams_json)
 teams := object.get(org_teams, "ministryofjustice", [])
 
-canonical_environment(environment) := "prod" if lower(environment) in {"prod", "production"}
-
-canonical_environment(environment) := "preprod" if lower(environment) in {"preprod", "pre-prod", "pre-production"}
-
-canonical_environment(environment) := lower(environment) if {
-	not lower(environment) in {"prod", "production", "preprod", "pre-prod", "pre-production"}
+canonical_environment(environment) := object.get(environment_aliases, name, name) if {
+	name := lower(trim_space(environment))
 }
 
 # Branch guardrail:
@@ -37,62 +55,129 @@ has_acl_environment(environment) if {
 	object.get(acl_by_environment, canonical_environment(environment), null) != null
 }
 
-environment_namespace_team_access(environment) := object.get(
-	acl_by_environment,
-	canonical_environment(environment),
-	{},
-) if {
+acl_environment(environment) := canonical_environment(environment) if {
 	has_acl_environment(environment)
 }
 
-environment_namespace_team_access(environment) := object.get(
-	acl_by_environment,
-	configured_default_environment,
-	{},
-) if {
+acl_environment(environment) := configured_default_environment if {
+	not has_acl_environment(environment)
+}
+
+base_namespace_team_access(environment) := object.get(acl_by_environment, acl_environment(environment), {})
+
+base_namespace_role_access(environment) := object.get(roles_by_environment, acl_environment(environment), {})
+
+# Branch ACL guardrail:
+# - A branch can only add access for namespaces it introduces. Namespaces the
+#   base environment already has keep the base ACLs, so a branch can't grant
+#   itself access to an existing namespace by editing its access.yml.
+branch_namespace_team_access(environment) := {namespace: mapped_teams |
 	not has_acl_environment(environment)
-	configured_default_environment != ""
+	some namespace, mapped_teams in object.get(branch_acl_by_environment, lower(trim_space(environment)), {})
+	not namespace in object.keys(base_namespace_team_access(environment))
 }
 
-environment_namespace_team_access(environment) := {} if {
+branch_namespace_role_access(environment) := {namespace: roles |
 	not has_acl_environment(environment)
-	configured_default_environment == ""
+	some namespace, roles in object.get(branch_roles_by_environment, lower(trim_space(environment)), {})
+	not namespace in object.keys(base_namespace_team_access(environment))
 }
 
+environment_namespace_team_access(environment) := object.union(
+	branch_namespace_team_access(environment),
+	base_namespace_team_access(environment),
+)
+
+environment_namespace_role_access(environment) := object.union(
+	branch_namespace_role_access(environment),
+	base_namespace_role_access(environment),
+)
+
 namespace_writer_teams := object.get(
 	environment_namespace_team_access(input.request.environment),
 	input.request.namespace,
 	[],
 )
 
+namespace_roles := object.get(
+	environment_namespace_role_access(input.request.environment),
+	input.request.namespace,
+	{},
+)
+
 has_correct_team if {
 	some team in namespace_writer_teams
 	team in teams
 }
 
+# Role guardrail:
+# - Writers (namespace_team_access) may take any action in their namespace.
+# - Readers may only read; togglers may read and update existing flags, but not
+#   create or delete. Flipt doesn't pass the changed fields to the policy, so
+#   in writable environments a toggler can change any field of an existing
+#   flag; only the flag-approval workflow holds toggler-approved PRs to
+#   `enabled` changes.
+role_actions := {
+	"readers": {"read"},
+	"togglers": {"read", "update"},
+}
+
+has_role_permission if {
+	some role, actions in role_actions
+	input.request.action in actions
+	some team in object.get(namespace_roles, role, [])
+	team in teams
+}
+
+# Teams holding any role (writer, toggler or reader) in each namespace.
+environment_namespace_teams(environment) := {namespace: mapped_teams |
+	some namespace, _ in object.union(
+		environment_namespace_team_access(environment),
+		environment_namespace_role_access(environment),
+	)
+	mapped_teams := array.concat(
+		object.get(environment_namespace_team_access(environment), namespace, []),
+		array.concat(
+			object.get(object.get(environment_namespace_role_access(environment), namespace, {}), "readers", []),
+			object.get(object.get(environment_namespace_role_access(environment), namespace, {}), "togglers", []),
+		),
+	)
+}
+
 has_any_namespace_access(environment) if {
-	some namespace, mapped_teams in environment_namespace_team_access(environment)
+	some namespace, mapped_teams in environment_namespace_teams(environment)
 	some team in mapped_teams
 	team in teams
 }
 
 is_admin if {
-	"hmpps-feature-flag-admins" in teams
+	some team in admin_teams
+	team in teams
 }
 
-# Prod guardrail:
-# - The base production environment is read-only through Flipt.
-# - Match both short key (`prod`) and display key (`Production`).
-# - Users can branch the prod environment and make changes on the branch,
+# Read-only guardrail:
+# - Base environments listed in read_only_environments (prod) are read-only
+#   through Flipt.
+# - Aliases are resolved first, so both `prod` and `Production` match.
+# - Users can branch a read-only environment and make changes on the branch,
 #   because branch environment keys are user-defined (e.g. "my-fix") and
-#   won't match the base environment names below.
+#   won't match the base environment names.
 # - Changes go live when the branch is merged back via PR.
-is_prod_environment if canonical_environment(input.request.environment) == "prod"
+# - Data without a read_only_environments list fails closed: every
+#   environment is read-only.
+is_read_only_environment if {
+	not "read_only_environments" in object.keys(authz_config)
+}
+
+is_read_only_environment if {
+	some environment in authz_config.read_only_environments
+	canonical_environment(environment) == canonical_environment(input.request.environment)
+}
 
 is_mutating_action if input.request.action in {"create", "update", "delete"}
 
-is_prod_mutation if {
-	is_prod_environment
+is_read_only_mutation if {
+	is_read_only_environment
 	is_mutating_action
 }
 
@@ -104,39 +189,68 @@ is_namespace_mutation if {
 	is_mutating_action
 }
 
+# Schema guardrail:
+# - acl-data.json records the schema_version it was generated with. Data from a
+#   generator this policy doesn't understand denies everything rather than
+#   being misread.
+# - Data without a schema_version predates versioning and is read as version 1.
+supported_schema_versions := {1}
+
+default schema_version := 1
+
+schema_version := data.schema_version # regal ignore: unresolved-reference
+
+is_supported_schema if schema_version in supported_schema_versions
+
 default allow := false
 
 # METADATA
 # entrypoint: true
 allow if {
+	is_supported_schema
 	is_admin
-	not is_prod_mutation
+	not is_read_only_mutation
 	not is_namespace_mutation
 }
 
 allow if {
+	is_supported_schema
 	input.request.scope == "environment"
 	input.request.action == "read"
 	has_any_namespace_access(input.request.environment)
 }
 
 allow if {
+	is_supported_schema
 	input.request.scope == "namespace"
 	has_correct_team
-	not is_prod_mutation
+	not is_read_only_mutation
+}
+
+allow if {
+	is_supported_schema
+	input.request.scope == "namespace"
+	has_role_permission
+	not is_read_only_mutation
 }
 
 viewable_namespaces(env) := ["*"] if {
+	# regal ignore: external-reference
+	is_supported_schema
+
 	# regal ignore: external-reference
 	is_admin
 }
 
 else := [ns |
+	# regal ignore: external-reference
+	is_supported_schema
+
 	# regal ignore: external-reference
 	not is_admin
 
 	# regal ignore: external-reference
-	some ns, mapped_teams in environment_namespace_team_access(env)
+	some ns, mapped_teams in environment_namespace_teams(env)
 	some t in mapped_teams
 
 	# regal ignore: external-reference
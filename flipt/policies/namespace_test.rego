@@ -4,6 +4,19 @@ package flipt.authz.v2_test
 import data.flipt.authz.v2 as flipt
 import rego.v1
 
+# Mirrors flags/acl-config.yml as embedded by flagctl acl generate.
+authz_config := {
+	"admin_teams": ["hmpps-feature-flag-admins"],
+	"environment_aliases": {
+		"prod": "prod",
+		"production": "prod",
+		"preprod": "preprod",
+		"pre-prod": "preprod",
+		"pre-production": "preprod",
+	},
+	"read_only_environments": ["prod"],
+}
+
 github_input(namespace, action, teams_json) := github_input_in_env(namespace, action, teams_json, "dev")
 
 github_input_in_env(namespace, action, teams_json, environment) := {
@@ -22,6 +35,7 @@ test_team_namespace_allowed[action] if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input("a-team", action, "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}")
 		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_team_namespace_not_allowed[resource_action] if {
@@ -31,6 +45,7 @@ test_team_namespace_not_allowed[resource_action] if {
 
 	# regal ignore: line-length
 	not flipt.allow with input as github_input("random-namespace", action, "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_legacy_mapping_allowed[action] if {
@@ -39,6 +54,7 @@ test_legacy_mapping_allowed[action] if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input("ProbationInCourt", action, "{\"ministryofjustice\":[\"hmpps-probation-in-court\"]}")
 		with data.namespace_team_access as {"dev": {"ProbationInCourt": ["hmpps-probation-in-court"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_explicit_mapping_hyphenated_namespace_allowed[action] if {
@@ -47,6 +63,7 @@ test_explicit_mapping_hyphenated_namespace_allowed[action] if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input("community-accommodation", action, "{\"ministryofjustice\":[\"hmpps-community-accommodation\"]}")
 		with data.namespace_team_access as {"dev": {"community-accommodation": ["hmpps-community-accommodation"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_admin_allowed[action] if {
@@ -54,44 +71,52 @@ test_admin_allowed[action] if {
 
 	# regal ignore: line-length
 	flipt.allow with input as github_input("random-namespace", action, "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_prod_team_namespace_read_allowed if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input_in_env("a-team", "read", "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}", "prod")
 		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_prod_team_namespace_update_not_allowed if {
 	# regal ignore: line-length
 	not flipt.allow with input as github_input_in_env("a-team", "update", "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}", "prod")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_prod_team_namespace_delete_not_allowed if {
 	# regal ignore: line-length
 	not flipt.allow with input as github_input_in_env("a-team", "delete", "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}", "prod")
 		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_prod_admin_read_allowed if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input_in_env("random-namespace", "read", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}", "prod")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_prod_admin_update_not_allowed if {
 	# regal ignore: line-length
 	not flipt.allow with input as github_input_in_env("random-namespace", "update", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}", "prod")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_production_team_namespace_read_allowed if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input_in_env("a-team", "read", "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}", "Production")
 		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_production_team_namespace_update_not_allowed if {
 	# regal ignore: line-length
 	not flipt.allow with input as github_input_in_env("a-team", "update", "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}", "Production")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 # Prod branch tests: branched environments get a user-defined key (e.g. "my-fix")
@@ -102,7 +127,7 @@ test_prod_branch_team_allowed[action] if {
 	# regal ignore: line-length
 	flipt.allow with input as github_input_in_env("a-team", action, "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}", "my-prod-fix")
 		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
-		with data.authz_config.default_environment as "prod" # regal ignore: unresolved-reference
+		with data.authz_config as object.union(authz_config, {"default_environment": "prod"}) # regal ignore: unresolved-reference,line-length
 }
 
 test_prod_branch_admin_allowed[action] if {
@@ -110,6 +135,53 @@ test_prod_branch_admin_allowed[action] if {
 
 	# regal ignore: line-length
 	flipt.allow with input as github_input_in_env("random-namespace", action, "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}", "my-prod-fix")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+# Branch ACL tests: a branch's own access.yml only counts for namespaces the
+# base environment doesn't have yet.
+test_branch_new_namespace_team_allowed[action] if {
+	some action in ["create", "update", "delete", "read"]
+
+	# regal ignore: line-length
+	flipt.allow with input as github_input_in_env("new-namespace", action, "{\"ministryofjustice\":[\"new-team\"]}", "add-new-namespace")
+		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.branch_team_access as {"add-new-namespace": {"new-namespace": ["new-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"default_environment": "prod"}) # regal ignore: unresolved-reference,line-length
+}
+
+test_branch_existing_namespace_grant_ignored if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input_in_env("a-team", "update", "{\"ministryofjustice\":[\"other-team\"]}", "grant-myself")
+		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.branch_team_access as {"grant-myself": {"a-team": ["other-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"default_environment": "prod"}) # regal ignore: unresolved-reference,line-length
+}
+
+test_branch_existing_namespace_keeps_base_access if {
+	# regal ignore: line-length
+	flipt.allow with input as github_input_in_env("a-team", "update", "{\"ministryofjustice\":[\"a-team\"]}", "grant-myself")
+		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.branch_team_access as {"grant-myself": {"a-team": ["other-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"default_environment": "prod"}) # regal ignore: unresolved-reference,line-length
+}
+
+test_branch_acl_ignored_for_base_environment if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input_in_env("new-namespace", "read", "{\"ministryofjustice\":[\"new-team\"]}", "prod")
+		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.branch_team_access as {"prod": {"new-namespace": ["new-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"default_environment": "prod"}) # regal ignore: unresolved-reference,line-length
+}
+
+test_branch_viewable_namespaces_include_new_namespace if {
+	# regal ignore: line-length
+	namespaces := flipt.viewable_namespaces("add-new-namespace") with input as github_input_in_env("new-namespace", "read", "{\"ministryofjustice\":[\"new-team\"]}", "add-new-namespace")
+		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.branch_team_access as {"add-new-namespace": {"new-namespace": ["new-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"default_environment": "prod"}) # regal ignore: unresolved-reference,line-length
+
+	namespaces == ["new-namespace"]
 }
 
 test_namespace_mutation_blocked_for_admin[action] if {
@@ -117,11 +189,13 @@ test_namespace_mutation_blocked_for_admin[action] if {
 
 	# regal ignore: line-length
 	not flipt.allow with input as env_scope_input("a-team", action, "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_namespace_read_allowed_for_admin if {
 	# regal ignore: line-length
 	flipt.allow with input as env_scope_input("a-team", "read", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_namespace_mutation_blocked_for_team[action] if {
@@ -129,17 +203,148 @@ test_namespace_mutation_blocked_for_team[action] if {
 
 	# regal ignore: line-length
 	not flipt.allow with input as env_scope_input("a-team", action, "{\"ministryofjustice\":[\"a-team\",\"another-team\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_viewable_namespaces_admin if {
 	# regal ignore: line-length
 	flipt.viewable_namespaces("dev") == ["*"] with input as github_input("ignored", "read", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 }
 
 test_viewable_namespaces_team_uses_access_mapping if {
 	# regal ignore: line-length
 	namespaces := flipt.viewable_namespaces("dev") with input as github_input("ignored", "read", "{\"ministryofjustice\":[\"a-team\",\"hmpps-probation-in-court\"]}")
 		with data.namespace_team_access as {"dev": {"a-team-ns": ["a-team"], "ProbationInCourt": ["hmpps-probation-in-court"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
 	"a-team-ns" in namespaces
 	"ProbationInCourt" in namespaces
 }
+
+test_reader_read_allowed if {
+	# regal ignore: line-length
+	flipt.allow with input as github_input("a-team", "read", "{\"ministryofjustice\":[\"b-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"dev": {"a-team": {"readers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+test_reader_mutation_not_allowed[action] if {
+	some action in ["create", "update", "delete"]
+
+	# regal ignore: line-length
+	not flipt.allow with input as github_input("a-team", action, "{\"ministryofjustice\":[\"b-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"dev": {"a-team": {"readers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+test_toggler_allowed[action] if {
+	some action in ["read", "update"]
+
+	# regal ignore: line-length
+	flipt.allow with input as github_input("a-team", action, "{\"ministryofjustice\":[\"b-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"dev": {"a-team": {"togglers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+test_toggler_create_delete_not_allowed[action] if {
+	some action in ["create", "delete"]
+
+	# regal ignore: line-length
+	not flipt.allow with input as github_input("a-team", action, "{\"ministryofjustice\":[\"b-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"dev": {"a-team": {"togglers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+test_prod_toggler_update_not_allowed if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input_in_env("a-team", "update", "{\"ministryofjustice\":[\"b-team\"]}", "prod")
+		with data.namespace_team_access as {"prod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"prod": {"a-team": {"togglers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+test_environment_read_allowed_for_reader if {
+	# regal ignore: line-length
+	flipt.allow with input as env_scope_input("a-team", "read", "{\"ministryofjustice\":[\"b-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"dev": {"a-team": {"readers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+}
+
+test_viewable_namespaces_includes_reader_namespaces if {
+	# regal ignore: line-length
+	namespaces := flipt.viewable_namespaces("dev") with input as github_input("ignored", "read", "{\"ministryofjustice\":[\"b-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team-ns": ["a-team"], "other-ns": ["other-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.namespace_role_access as {"dev": {"a-team-ns": {"readers": ["b-team"]}}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+	"a-team-ns" in namespaces
+	not "other-ns" in namespaces
+}
+
+test_admin_teams_read_from_config if {
+	# regal ignore: line-length
+	flipt.allow with input as github_input("random-namespace", "update", "{\"ministryofjustice\":[\"another-admin-team\"]}")
+		with data.authz_config as object.union(authz_config, {"admin_teams": ["another-admin-team"]}) # regal ignore: unresolved-reference,line-length
+}
+
+test_admin_not_allowed_when_config_lists_no_admins if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input("random-namespace", "read", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as object.union(authz_config, {"admin_teams": []}) # regal ignore: unresolved-reference,line-length
+}
+
+test_read_only_environments_read_from_config if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input_in_env("a-team", "update", "{\"ministryofjustice\":[\"a-team\"]}", "preprod")
+		with data.namespace_team_access as {"preprod": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"read_only_environments": ["prod", "preprod"]}) # regal ignore: unresolved-reference,line-length
+}
+
+test_environment_alias_read_from_config if {
+	# regal ignore: line-length
+	flipt.allow with input as github_input_in_env("a-team", "read", "{\"ministryofjustice\":[\"a-team\"]}", "Sandbox")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as object.union(authz_config, {"environment_aliases": {"sandbox": "dev"}}) # regal ignore: unresolved-reference,line-length
+}
+
+test_mutation_not_allowed_without_read_only_config if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input("a-team", "update", "{\"ministryofjustice\":[\"a-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+}
+
+test_supported_schema_version_allowed if {
+	# regal ignore: line-length
+	flipt.allow with input as github_input("a-team", "update", "{\"ministryofjustice\":[\"a-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+		with data.schema_version as 1 # regal ignore: unresolved-reference
+}
+
+test_unknown_schema_version_not_allowed[action] if {
+	some action in ["create", "update", "delete", "read"]
+
+	# regal ignore: line-length
+	not flipt.allow with input as github_input("a-team", action, "{\"ministryofjustice\":[\"a-team\"]}")
+		with data.namespace_team_access as {"dev": {"a-team": ["a-team"]}} # regal ignore: unresolved-reference,line-length
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+		with data.schema_version as 2 # regal ignore: unresolved-reference
+}
+
+test_unknown_schema_version_admin_not_allowed if {
+	# regal ignore: line-length
+	not flipt.allow with input as github_input("random-namespace", "read", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+		with data.schema_version as 2 # regal ignore: unresolved-reference
+}
+
+test_viewable_namespaces_empty_for_unknown_schema_version if {
+	# regal ignore: line-length
+	flipt.viewable_namespaces("dev") == [] with input as github_input("ignored", "read", "{\"ministryofjustice\":[\"hmpps-feature-flag-admins\"]}")
+		with data.authz_config as authz_config # regal ignore: unresolved-reference
+		with data.schema_version as 2 # regal ignore: unresolved-reference
+}
@@ -0,0 +1,131 @@
+// Package acl builds the acl-data.json Flipt's OPA authorization policy reads
+// from the access files under flags/: which GitHub teams hold which role in
+// each namespace of each environment, plus the repo-wide settings in
+// flags/acl-config.yml.
+package acl
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+)
+
+// SchemaVersion is bumped whenever acl-data.json changes shape in a way the
+// policy needs to know about; the policy denies everything for versions it
+// doesn't support.
+const SchemaVersion = 1
+
+type Data struct {
+	SchemaVersion       int                              `json:"schema_version"`
+	GeneratedAt         time.Time                        `json:"generated_at"`
+	SourceRevision      string                           `json:"source_revision,omitempty"`
+	AuthzConfig         AuthzConfig                      `json:"authz_config,omitempty"`
+	NamespaceTeamAccess map[string]map[string][]string   `json:"namespace_team_access"`
+	NamespaceRoleAccess map[string]map[string]RoleAccess `json:"namespace_role_access"`
+	BranchTeamAccess    map[string]map[string][]string   `json:"branch_team_access,omitempty"`
+	BranchRoleAccess    map[string]map[string]RoleAccess `json:"branch_role_access,omitempty"`
+}
+
+// RoleAccess holds the teams granted less than write access to a namespace.
+// Writers stay in namespace_team_access so older policies keep working
+// against newer data.
+type RoleAccess struct {
+	Readers  []string `json:"readers,omitempty"`
+	Togglers []string `json:"togglers,omitempty"`
+}
+
+type AuthzConfig struct {
+	DefaultEnvironment   string            `json:"default_environment,omitempty"`
+	AdminTeams           []string          `json:"admin_teams"`
+	EnvironmentAliases   map[string]string `json:"environment_aliases"`
+	ReadOnlyEnvironments []string          `json:"read_only_environments"`
+}
+
+// RoleTeams returns the teams holding each role in a namespace, keyed by the
+// access.yml field name.
+func (d Data) RoleTeams(environment, namespace string) map[string][]string {
+	roles := d.NamespaceRoleAccess[environment][namespace]
+	return map[string][]string{
+		"writers":  d.NamespaceTeamAccess[environment][namespace],
+		"readers":  roles.Readers,
+		"togglers": roles.Togglers,
+	}
+}
+
+// Entries renders the namespace access as one
+// "<section>.<env>.<namespace>[.<role>]: [teams]" line per role, sorted, for
+// previewing what a change to the flags directory does to acl-data.json.
+func (d Data) Entries() []string {
+	var entries []string
+
+	for environment, namespaces := range d.NamespaceTeamAccess {
+		for namespace := range namespaces {
+			roles := d.RoleTeams(environment, namespace)
+			for _, r := range []struct{ section, role string }{
+				{"namespace_team_access", ""},
+				{"namespace_role_access", "readers"},
+				{"namespace_role_access", "togglers"},
+			} {
+				teams := roles["writers"]
+				if r.role != "" {
+					teams = roles[r.role]
+				}
+				if len(teams) == 0 {
+					continue
+				}
+
+				quoted := make([]string, 0, len(teams))
+				for _, team := range teams {
+					quoted = append(quoted, fmt.Sprintf("%q", team))
+				}
+
+				entry := strings.Join([]string{r.section, environment, namespace}, ".")
+				if r.role != "" {
+					entry += "." + r.role
+				}
+				entries = append(entries, fmt.Sprintf("%s: [%s]", entry, strings.Join(quoted, ", ")))
+			}
+		}
+	}
+
+	sort.Strings(entries)
+	return entries
+}
+
+// RenameNamespace moves a namespace's access in one environment to a new key.
+func (d *Data) RenameNamespace(environment, from, to string) {
+	if from == to {
+		return
+	}
+	if teams, ok := d.NamespaceTeamAccess[environment][from]; ok {
+		d.NamespaceTeamAccess[environment][to] = teams
+	}
+	if roles, ok := d.NamespaceRoleAccess[environment][from]; ok {
+		d.NamespaceRoleAccess[environment][to] = roles
+	}
+	d.RemoveNamespace(environment, from)
+}
+
+// RemoveNamespace drops a namespace's access in one environment.
+func (d *Data) RemoveNamespace(environment, namespace string) {
+	delete(d.NamespaceTeamAccess[environment], namespace)
+	delete(d.NamespaceRoleAccess[environment], namespace)
+}
+
+// ActiveTeams returns the teams whose grants haven't expired at now, sorted
+// and deduplicated.
+func ActiveTeams(grants []flagfile.AccessGrant, now time.Time) []string {
+	var teams []string
+	for _, g := range grants {
+		if g.Team == "" || (g.Expires != nil && !now.Before(*g.Expires)) {
+			continue
+		}
+		teams = append(teams, g.Team)
+	}
+	sort.Strings(teams)
+	return slices.Compact(teams)
+}
@@ -0,0 +1,333 @@
+package acl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"go.uber.org/zap"
+)
+
+// writeTree writes files (relative path → content) under a temporary flags
+// directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	flagsDir := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(flagsDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return flagsDir
+}
+
+func TestCanonicalEnvironment(t *testing.T) {
+	aliases := DefaultConfig.Aliases()
+
+	tests := []struct {
+		in, want string
+	}{
+		{"prod", "prod"},
+		{"Production", "prod"},
+		{" pre-prod ", "preprod"},
+		{"Dev", "dev"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := CanonicalEnvironment(aliases, tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActiveTeams(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		grants []flagfile.AccessGrant
+		want   []string
+	}{
+		{"none", nil, nil},
+		{"sorted and deduplicated", []flagfile.AccessGrant{{Team: "b"}, {Team: "a"}, {Team: "b"}}, []string{"a", "b"}},
+		{"expired left out", []flagfile.AccessGrant{{Team: "a", Expires: &past}, {Team: "b", Expires: &future}}, []string{"b"}},
+		{"expiring now left out", []flagfile.AccessGrant{{Team: "a", Expires: &now}}, nil},
+		{"empty team left out", []flagfile.AccessGrant{{Team: ""}, {Team: "a"}}, []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ActiveTeams(tt.grants, now); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		teams    map[string]map[string][]string
+		roles    map[string]map[string]RoleAccess
+		failures int
+	}{
+		{
+			name: "namespace key from features file",
+			files: map[string]string{
+				"dev/probation-in-court/features.yml": "namespace:\n    key: ProbationInCourt\n    name: Probation in court\n",
+				"dev/probation-in-court/access.yml":   "writers:\n    - team-a\nreaders:\n    - team-b\n",
+			},
+			teams: map[string]map[string][]string{"dev": {"ProbationInCourt": {"team-a"}}},
+			roles: map[string]map[string]RoleAccess{"dev": {"ProbationInCourt": {Readers: []string{"team-b"}}}},
+		},
+		{
+			name: "shared access and aliases",
+			files: map[string]string{
+				"access/my-service.yml":              "writers:\n    - team-a\n",
+				"production/my-service/features.yml": "namespace:\n    key: my-service\n    name: My service\n",
+			},
+			teams: map[string]map[string][]string{"prod": {"my-service": {"team-a"}}},
+			roles: map[string]map[string]RoleAccess{"prod": {}},
+		},
+		{
+			name: "no writers is a failure",
+			files: map[string]string{
+				"dev/my-service/access.yml": "readers:\n    - team-a\n",
+			},
+			teams:    map[string]map[string][]string{"dev": {}},
+			roles:    map[string]map[string]RoleAccess{"dev": {}},
+			failures: 1,
+		},
+		{
+			name: "expired writers are skipped",
+			files: map[string]string{
+				"dev/my-service/access.yml": "writers:\n    - team: team-a\n      expires: 2020-01-01T00:00:00Z\n",
+			},
+			teams: map[string]map[string][]string{"dev": {}},
+			roles: map[string]map[string]RoleAccess{"dev": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagsDir := writeTree(t, tt.files)
+
+			data, failures, err := Collect(context.Background(), zap.NewNop(), flagsDir, DefaultConfig.Aliases(), time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(data.NamespaceTeamAccess, tt.teams) {
+				t.Errorf("team access: got %v, want %v", data.NamespaceTeamAccess, tt.teams)
+			}
+			if !reflect.DeepEqual(data.NamespaceRoleAccess, tt.roles) {
+				t.Errorf("role access: got %v, want %v", data.NamespaceRoleAccess, tt.roles)
+			}
+			if len(failures) != tt.failures {
+				t.Errorf("got failures %+v, want %d", failures, tt.failures)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := func(writers []string, roles RoleAccess) Data {
+		return Data{
+			NamespaceTeamAccess: map[string]map[string][]string{"dev": {"ns": writers}},
+			NamespaceRoleAccess: map[string]map[string]RoleAccess{"dev": {"ns": roles}},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		previous, next Data
+		want           []Change
+	}{
+		{"unchanged", data([]string{"a"}, RoleAccess{}), data([]string{"a"}, RoleAccess{}), nil},
+		{
+			name:     "writer swapped",
+			previous: data([]string{"a"}, RoleAccess{}),
+			next:     data([]string{"b"}, RoleAccess{}),
+			want: []Change{
+				{now, "dev", "ns", "a", "writers", "removed"},
+				{now, "dev", "ns", "b", "writers", "added"},
+			},
+		},
+		{
+			name:     "toggler added",
+			previous: data([]string{"a"}, RoleAccess{}),
+			next:     data([]string{"a"}, RoleAccess{Togglers: []string{"c"}}),
+			want:     []Change{{now, "dev", "ns", "c", "togglers", "added"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.previous, tt.next, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	first := []Change{{now, "dev", "ns", "a", "writers", "added"}}
+	second := []Change{
+		{now, "dev", "ns", "a", "writers", "removed"},
+		{now, "dev", "ns", "b", "writers", "added"},
+	}
+
+	// Each call appends, so the log keeps every generation's changes.
+	Audit(zap.NewNop(), first, auditPath)
+	Audit(zap.NewNop(), nil, auditPath)
+	Audit(zap.NewNop(), second, auditPath)
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Change
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var c Change
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		got = append(got, c)
+	}
+	if want := append(first, second...); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Without a path the changes are only logged.
+	Audit(zap.NewNop(), first, "")
+}
+
+func TestEntries(t *testing.T) {
+	newData := func() Data {
+		return Data{
+			NamespaceTeamAccess: map[string]map[string][]string{"dev": {"old": {"a", "b"}, "other": {"c"}}},
+			NamespaceRoleAccess: map[string]map[string]RoleAccess{"dev": {"old": {Readers: []string{"d"}}}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(d *Data)
+		want   []string
+	}{
+		{
+			name:   "as collected",
+			change: func(*Data) {},
+			want: []string{
+				`namespace_role_access.dev.old.readers: ["d"]`,
+				`namespace_team_access.dev.old: ["a", "b"]`,
+				`namespace_team_access.dev.other: ["c"]`,
+			},
+		},
+		{
+			name:   "renamed",
+			change: func(d *Data) { d.RenameNamespace("dev", "old", "new") },
+			want: []string{
+				`namespace_role_access.dev.new.readers: ["d"]`,
+				`namespace_team_access.dev.new: ["a", "b"]`,
+				`namespace_team_access.dev.other: ["c"]`,
+			},
+		},
+		{
+			name:   "removed",
+			change: func(d *Data) { d.RemoveNamespace("dev", "old") },
+			want:   []string{`namespace_team_access.dev.other: ["c"]`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newData()
+			tt.change(&d)
+			if got := d.Entries(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBranchCache(t *testing.T) {
+	repo := writeTree(t, map[string]string{"flags/dev/ns/access.yml": "writers:\n    - team-a\n"})
+
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "first")
+	git("update-ref", "refs/flipt/feature", "HEAD")
+
+	ctx := context.Background()
+	cache := &BranchCache{}
+	defer cache.Close()
+
+	writers := func() []string {
+		t.Helper()
+		teams, _, err := branchAccess(ctx, zap.NewNop(), repo, "flags", "refs/flipt/feature", cache, DefaultConfig.Aliases(), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return teams["dev"]["ns"]
+	}
+
+	if got := writers(); !slices.Equal(got, []string{"team-a"}) {
+		t.Fatalf("got writers %q, want [team-a]", got)
+	}
+	first := cache.trees["refs/flipt/feature"].dir
+
+	writers()
+	if dir := cache.trees["refs/flipt/feature"].dir; dir != first {
+		t.Errorf("unchanged ref was extracted again, to %s", dir)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "flags/dev/ns/access.yml"), []byte("writers:\n    - team-b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("commit", "-qam", "second")
+	git("update-ref", "refs/flipt/feature", "HEAD")
+
+	if got := writers(); !slices.Equal(got, []string{"team-b"}) {
+		t.Errorf("got writers %q after the ref moved, want [team-b]", got)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("stale tree %s wasn't removed", first)
+	}
+
+	cache.prune(map[string]string{})
+	if len(cache.trees) != 0 {
+		t.Errorf("deleted ref's tree wasn't pruned: %v", cache.trees)
+	}
+}
@@ -0,0 +1,96 @@
+package acl
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Change is a single team gaining or losing a role in a namespace.
+type Change struct {
+	Time        time.Time `json:"time"`
+	Environment string    `json:"environment"`
+	Namespace   string    `json:"namespace"`
+	Team        string    `json:"team"`
+	Role        string    `json:"role"`
+	Change      string    `json:"change"`
+}
+
+// Diff lists every team added to or removed from a namespace role between
+// two generations, sorted by environment, namespace, team, role.
+func Diff(previous, next Data, now time.Time) []Change {
+	var changes []Change
+
+	collect := func(from, to Data, change string) {
+		for environment, namespaces := range from.NamespaceTeamAccess {
+			for namespace := range namespaces {
+				existing := to.RoleTeams(environment, namespace)
+				for role, teams := range from.RoleTeams(environment, namespace) {
+					for _, team := range teams {
+						if !slices.Contains(existing[role], team) {
+							changes = append(changes, Change{now, environment, namespace, team, role, change})
+						}
+					}
+				}
+			}
+		}
+	}
+
+	collect(next, previous, "added")
+	collect(previous, next, "removed")
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Environment != b.Environment {
+			return a.Environment < b.Environment
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Team != b.Team {
+			return a.Team < b.Team
+		}
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return a.Change < b.Change
+	})
+
+	return changes
+}
+
+// Audit logs each ACL change as its own event and, if auditPath is
+// set, appends them to it as JSON lines.
+func Audit(logger *zap.Logger, changes []Change, auditPath string) {
+	for _, c := range changes {
+		logger.Info("ACL access "+c.Change,
+			zap.String("environment", c.Environment),
+			zap.String("namespace", c.Namespace),
+			zap.String("team", c.Team),
+			zap.String("role", c.Role),
+		)
+	}
+
+	if auditPath == "" || len(changes) == 0 {
+		return
+	}
+
+	f, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Error("failed to open audit log", zap.String("path", auditPath), zap.Error(err))
+		return
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, c := range changes {
+		if err := encoder.Encode(c); err != nil {
+			logger.Error("failed to write audit log", zap.String("path", auditPath), zap.Error(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package acl
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BranchSource says which git refs hold Flipt branch environments: every ref
+// under Prefix (named by what follows it), plus explicit environment → ref
+// pairs in Refs. With a Cache, each ref's files are only extracted again once
+// the ref has moved.
+type BranchSource struct {
+	Prefix string
+	Refs   map[string]string
+	Cache  *BranchCache
+}
+
+// BranchCache keeps each branch ref's flags directory extracted between
+// generations, keyed by the commit it was extracted from. The zero value is
+// ready to use; Close removes what it extracted.
+type BranchCache struct {
+	mu    sync.Mutex
+	trees map[string]branchTree
+}
+
+type branchTree struct {
+	commit string
+	dir    string
+}
+
+// extract returns a directory holding prefix as it stands at commit, the
+// commit ref points to, and a func to call once done with it. A nil cache
+// extracts afresh every time.
+func (c *BranchCache) extract(ctx context.Context, repoRoot, prefix, ref, commit string) (string, func(), error) {
+	if c == nil {
+		dir, err := extractTree(ctx, repoRoot, prefix, commit)
+		return dir, func() { os.RemoveAll(dir) }, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tree, ok := c.trees[ref]; ok && tree.commit == commit {
+		return tree.dir, func() {}, nil
+	}
+
+	dir, err := extractTree(ctx, repoRoot, prefix, commit)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if c.trees == nil {
+		c.trees = make(map[string]branchTree)
+	}
+	if tree, ok := c.trees[ref]; ok {
+		os.RemoveAll(tree.dir)
+	}
+	c.trees[ref] = branchTree{commit: commit, dir: dir}
+
+	return dir, func() {}, nil
+}
+
+// prune removes the trees of refs not in refs, e.g. deleted branches.
+func (c *BranchCache) prune(refs map[string]string) {
+	if c == nil {
+		return
+	}
+
+	live := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		live[ref] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ref, tree := range c.trees {
+		if !live[ref] {
+			os.RemoveAll(tree.dir)
+			delete(c.trees, ref)
+		}
+	}
+}
+
+// Close removes every tree the cache extracted.
+func (c *BranchCache) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ref, tree := range c.trees {
+		os.RemoveAll(tree.dir)
+		delete(c.trees, ref)
+	}
+	return nil
+}
+
+func (b BranchSource) Enabled() bool {
+	return b.Prefix != "" || len(b.Refs) > 0
+}
+
+// refs resolves the branch environments to git refs, listing Prefix in the
+// repository at repoRoot.
+func (b BranchSource) refs(ctx context.Context, repoRoot string) (map[string]string, error) {
+	refs := make(map[string]string, len(b.Refs))
+	for environment, ref := range b.Refs {
+		refs[strings.ToLower(environment)] = ref
+	}
+
+	if b.Prefix == "" {
+		return refs, nil
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "-C", repoRoot, "for-each-ref", "--format=%(refname)", b.Prefix).Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing refs under %s: %w", b.Prefix, err)
+	}
+
+	for _, ref := range strings.Fields(string(out)) {
+		environment := strings.ToLower(strings.TrimPrefix(ref, b.Prefix))
+		if _, explicit := refs[environment]; environment != "" && !explicit {
+			refs[environment] = ref
+		}
+	}
+
+	return refs, nil
+}
+
+// collectBranchAccess builds the ACLs each branch environment would have from
+// its own files: the default environment's namespaces as they stand on the
+// branch's ref. A branch that can't be read is logged and left out, so it
+// falls back to the default environment's ACLs in the policy.
+func collectBranchAccess(ctx context.Context, logger *zap.Logger, flagsDir string, branches BranchSource, aliases map[string]string, defaultEnvironment string, now time.Time) (map[string]map[string][]string, map[string]map[string]RoleAccess, error) {
+	teamAccess := make(map[string]map[string][]string)
+	roleAccess := make(map[string]map[string]RoleAccess)
+
+	if defaultEnvironment == "" {
+		logger.Warn("branch ACLs need FLIPT_DEFAULT_ENVIRONMENT, skipping branches")
+		return teamAccess, roleAccess, nil
+	}
+
+	repoRoot, prefix, err := gitPaths(ctx, flagsDir)
+	if err != nil {
+		logger.Warn("flags directory isn't in a git repository, skipping branches", zap.Error(err))
+		return teamAccess, roleAccess, nil
+	}
+
+	refs, err := branches.refs(ctx, repoRoot)
+	if err != nil {
+		logger.Warn("failed to list branch refs", zap.Error(err))
+		return teamAccess, roleAccess, nil
+	}
+
+	environments := make([]string, 0, len(refs))
+	for environment := range refs {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+
+	for _, environment := range environments {
+		branchTeams, branchRoles, err := branchAccess(ctx, logger, repoRoot, prefix, refs[environment], branches.Cache, aliases, now)
+		if errors.Is(err, context.Canceled) {
+			return nil, nil, err
+		}
+		if err != nil {
+			logger.Warn("skipping branch", zap.String("environment", environment), zap.String("ref", refs[environment]), zap.Error(err))
+			continue
+		}
+
+		teamAccess[environment] = branchTeams[defaultEnvironment]
+		roleAccess[environment] = branchRoles[defaultEnvironment]
+	}
+	branches.Cache.prune(refs)
+
+	return teamAccess, roleAccess, nil
+}
+
+// branchAccess collects the access of the flags directory as it stands at
+// ref, extracting it (or reusing cache's copy) first.
+func branchAccess(ctx context.Context, logger *zap.Logger, repoRoot, prefix, ref string, cache *BranchCache, aliases map[string]string, now time.Time) (map[string]map[string][]string, map[string]map[string]RoleAccess, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", repoRoot, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	dir, done, err := cache.extract(ctx, repoRoot, prefix, ref, commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	// A branch's broken files only cost that branch its own entries.
+	data, _, err := Collect(ctx, logger.With(zap.String("ref", ref)), filepath.Join(dir, prefix), aliases, now)
+	return data.NamespaceTeamAccess, data.NamespaceRoleAccess, err
+}
+
+// extractTree extracts prefix as it stands at commit into a new temporary
+// directory.
+func extractTree(ctx context.Context, repoRoot, prefix, commit string) (string, error) {
+	archive, err := exec.CommandContext(ctx, "git", "-C", repoRoot, "archive", "--format=tar", commit, "--", prefix).Output()
+	if err != nil {
+		return "", fmt.Errorf("archiving %s: %w", commit, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "acl-branch-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := untar(archive, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	return tmpDir, nil
+}
+
+// untar writes the regular files in archive under dir.
+func untar(archive []byte, dir string) error {
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || !filepath.IsLocal(header.Name) {
+			continue
+		}
+
+		path := filepath.Join(dir, header.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return err
+		}
+	}
+}
+
+// gitPaths returns the root of the git repository containing flagsDir and
+// flagsDir's path within it.
+func gitPaths(ctx context.Context, flagsDir string) (string, string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", flagsDir, "rev-parse", "--show-toplevel", "--show-prefix").Output()
+	if err != nil {
+		return "", "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(lines) < 2 {
+		return strings.TrimSpace(lines[0]), ".", nil
+	}
+
+	return lines[0], strings.TrimSuffix(lines[1], "/"), nil
+}
@@ -0,0 +1,100 @@
+package acl
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteBundle writes an OPA bundle tarball to bundlePath containing the
+// generated data, every non-test policy in policyDir, and a .manifest carrying
+// revision. The bundle isn't byte-for-byte reproducible: data.json carries
+// generated_at, so two runs over the same commit differ there.
+func WriteBundle(bundlePath string, policyDir string, data Data, revision string) error {
+	dataJSON, _ := json.MarshalIndent(data, "", "  ")
+
+	manifest, _ := json.MarshalIndent(struct {
+		Revision string   `json:"revision"`
+		Roots    []string `json:"roots"`
+	}{
+		Revision: revision,
+		Roots: []string{
+			"flipt/authz/v2",
+			"schema_version",
+			"generated_at",
+			"source_revision",
+			"authz_config",
+			"namespace_team_access",
+			"namespace_role_access",
+			"branch_team_access",
+			"branch_role_access",
+		},
+	}, "", "  ")
+
+	files := map[string][]byte{
+		".manifest": append(manifest, '\n'),
+		"data.json": append(dataJSON, '\n'),
+	}
+
+	policies, _ := filepath.Glob(filepath.Join(policyDir, "*.rego"))
+	if len(policies) == 0 {
+		return fmt.Errorf("no policies found in %s", policyDir)
+	}
+	for _, policy := range policies {
+		if strings.HasSuffix(policy, "_test.rego") {
+			continue
+		}
+		content, err := os.ReadFile(policy)
+		if err != nil {
+			return err
+		}
+		files[filepath.Base(policy)] = content
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    "/" + name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	tmpPath := bundlePath + ".tmp"
+
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, bundlePath)
+}
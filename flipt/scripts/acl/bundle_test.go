@@ -0,0 +1,107 @@
+package acl
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// readBundle returns the files in a bundle tarball by name.
+func readBundle(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = content
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	policyDir := writeTree(t, map[string]string{
+		"namespace.rego":      "package flipt.authz.v2\n",
+		"namespace_test.rego": "package flipt.authz.v2_test\n",
+		"README.md":           "not a policy\n",
+	})
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	data := Data{SchemaVersion: SchemaVersion, NamespaceTeamAccess: map[string]map[string][]string{"dev": {"a": {"team-a"}}}}
+	if err := WriteBundle(bundlePath, policyDir, data, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readBundle(t, bundlePath)
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"/.manifest", "/data.json", "/namespace.rego"}; !slices.Equal(names, want) {
+		t.Errorf("files: got %q, want %q", names, want)
+	}
+
+	var manifest struct {
+		Revision string   `json:"revision"`
+		Roots    []string `json:"roots"`
+	}
+	if err := json.Unmarshal(files["/.manifest"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Revision != "abc123" {
+		t.Errorf("revision: got %q, want %q", manifest.Revision, "abc123")
+	}
+	if !slices.Contains(manifest.Roots, "namespace_team_access") {
+		t.Errorf("roots %q don't cover namespace_team_access", manifest.Roots)
+	}
+
+	var written Data
+	if err := json.Unmarshal(files["/data.json"], &written); err != nil {
+		t.Fatal(err)
+	}
+	if got := written.NamespaceTeamAccess["dev"]["a"]; !slices.Equal(got, []string{"team-a"}) {
+		t.Errorf("data.json: got %q, want team-a", got)
+	}
+
+	if _, err := os.Stat(bundlePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestWriteBundleNoPolicies(t *testing.T) {
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	if err := WriteBundle(bundlePath, t.TempDir(), Data{}, ""); err == nil {
+		t.Fatal("expected an error for a policy directory with no policies")
+	}
+	if _, err := os.Stat(bundlePath); !os.IsNotExist(err) {
+		t.Errorf("bundle written without policies: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is flags/acl-config.yml: repo-wide authorization settings that are
+// embedded in the generated data so the policy doesn't hard-code them.
+type Config struct {
+	AdminTeams           []string            `yaml:"adminTeams"`
+	EnvironmentAliases   map[string][]string `yaml:"environmentAliases"`
+	ReadOnlyEnvironments []string            `yaml:"readOnlyEnvironments"`
+}
+
+// DefaultConfig applies when a flags directory has no acl-config.yml (e.g. the
+// smoke test fixtures).
+var DefaultConfig = Config{
+	AdminTeams: []string{"hmpps-feature-flag-admins"},
+	EnvironmentAliases: map[string][]string{
+		"prod":    {"production"},
+		"preprod": {"pre-prod", "pre-production"},
+	},
+	ReadOnlyEnvironments: []string{"prod"},
+}
+
+// LoadConfig reads flags/acl-config.yml, falling back to DefaultConfig if it
+// doesn't exist.
+func LoadConfig(flagsDir string) (Config, error) {
+	data, err := os.ReadFile(filepath.Join(flagsDir, "acl-config.yml"))
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultConfig, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Aliases maps every lower-cased alias, and each canonical name itself, to its
+// canonical environment name.
+func (c Config) Aliases() map[string]string {
+	aliases := make(map[string]string)
+	for canonical, names := range c.EnvironmentAliases {
+		canonical = strings.ToLower(strings.TrimSpace(canonical))
+		aliases[canonical] = canonical
+		for _, name := range names {
+			aliases[strings.ToLower(strings.TrimSpace(name))] = canonical
+		}
+	}
+	return aliases
+}
+
+func (c Config) AuthzConfig(defaultEnvironment string) AuthzConfig {
+	aliases := c.Aliases()
+
+	readOnly := make([]string, 0, len(c.ReadOnlyEnvironments))
+	for _, environment := range c.ReadOnlyEnvironments {
+		readOnly = append(readOnly, CanonicalEnvironment(aliases, environment))
+	}
+
+	adminTeams := c.AdminTeams
+	if adminTeams == nil {
+		adminTeams = []string{}
+	}
+
+	return AuthzConfig{
+		DefaultEnvironment:   CanonicalEnvironment(aliases, defaultEnvironment),
+		AdminTeams:           adminTeams,
+		EnvironmentAliases:   aliases,
+		ReadOnlyEnvironments: readOnly,
+	}
+}
+
+// ReadOnly reports whether environment, or the environment it's an alias of,
+// is read-only: changed only through reviewed PRs, never from the UI.
+func (c Config) ReadOnly(environment string) bool {
+	return slices.Contains(c.AuthzConfig("").ReadOnlyEnvironments, CanonicalEnvironment(c.Aliases(), environment))
+}
+
+// CanonicalEnvironment resolves an environment name through aliases (see
+// Config.Aliases), lower-casing names that aren't an alias.
+func CanonicalEnvironment(aliases map[string]string, environment string) string {
+	name := strings.ToLower(strings.TrimSpace(environment))
+	if canonical, ok := aliases[name]; ok {
+		return canonical
+	}
+	return name
+}
@@ -0,0 +1,237 @@
+package acl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"go.uber.org/zap"
+)
+
+// Failure records an access.yml that could not be turned into ACL entries,
+// i.e. a namespace whose teams would lose access if the generated data were
+// written out.
+type Failure struct {
+	Environment string `json:"environment,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Path        string `json:"path"`
+	Reason      string `json:"reason"`
+}
+
+// ConfigFailure records an acl-config.yml that could not be loaded. Every
+// namespace is affected rather than one, since the admin teams, aliases and
+// read-only environments would fall back to the defaults.
+type ConfigFailure struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// failureState is written alongside the ACL data while strict mode is holding
+// back a broken generation, so operators can alert on its presence.
+type failureState struct {
+	FailedAt time.Time      `json:"failed_at"`
+	Config   *ConfigFailure `json:"config,omitempty"`
+	Failures []Failure      `json:"failures"`
+}
+
+// ErrStrictFailure is returned by Generate when strict mode refused to replace
+// the last known-good ACL data.
+var ErrStrictFailure = errors.New("ACL config or access files failed to load, kept last known-good ACL data")
+
+func failureStatePath(outputPath string) string {
+	return outputPath + ".failed"
+}
+
+// Collect reads the effective access for every namespace under
+// flags/<env>/<namespace>/ into the namespace_team_access and
+// namespace_role_access of the returned Data, returning the access files it
+// had to skip.
+func Collect(ctx context.Context, logger *zap.Logger, flagsDir string, aliases map[string]string, now time.Time) (Data, []Failure, error) {
+	data := Data{
+		NamespaceTeamAccess: make(map[string]map[string][]string),
+		NamespaceRoleAccess: make(map[string]map[string]RoleAccess),
+	}
+
+	var failures []Failure
+
+	for _, nsDir := range flagfile.NamespaceDirs(flagsDir) {
+		if err := ctx.Err(); err != nil {
+			return Data{}, nil, err
+		}
+
+		envDir := filepath.Dir(nsDir)
+		environment := CanonicalEnvironment(aliases, filepath.Base(envDir))
+
+		namespace := flagfile.NamespaceKey(nsDir)
+		if namespace == "" {
+			namespace = filepath.Base(nsDir)
+		}
+
+		if _, exists := data.NamespaceTeamAccess[environment]; !exists {
+			data.NamespaceTeamAccess[environment] = make(map[string][]string)
+			data.NamespaceRoleAccess[environment] = make(map[string]RoleAccess)
+		}
+
+		access, sources, err := flagfile.LoadAccess(flagsDir, nsDir)
+		if len(sources) == 0 {
+			continue
+		}
+
+		accessPath := sources[len(sources)-1]
+		if err != nil {
+			logger.Warn("skipping unreadable access file", zap.String("path", accessPath), zap.Error(err))
+			failures = append(failures, Failure{environment, namespace, accessPath, err.Error()})
+			continue
+		}
+
+		if len(access.Writers) == 0 {
+			logger.Warn("skipping access file with no writers", zap.String("path", accessPath))
+			failures = append(failures, Failure{environment, namespace, accessPath, "no writers"})
+			continue
+		}
+
+		// Every writer having expired is the grants doing their job, not a
+		// broken file, so it doesn't trip strict mode.
+		writers := ActiveTeams(access.Writers, now)
+		if len(writers) == 0 {
+			logger.Warn("skipping access file whose writers have all expired", zap.String("path", accessPath))
+			continue
+		}
+
+		data.NamespaceTeamAccess[environment][namespace] = writers
+
+		readers := ActiveTeams(access.Readers, now)
+		togglers := ActiveTeams(access.Togglers, now)
+		if len(readers) > 0 || len(togglers) > 0 {
+			data.NamespaceRoleAccess[environment][namespace] = RoleAccess{
+				Readers:  readers,
+				Togglers: togglers,
+			}
+		}
+	}
+
+	return data, failures, nil
+}
+
+// Generate reads all access.yml files under flags/<env>/<namespace>/ (and the
+// shared flags/access/<namespace>.yml each one inherits from), builds a JSON
+// map of environment → namespace → writer teams (plus any reader and toggler
+// teams alongside it, and the repo-wide settings from flags/acl-config.yml),
+// and writes it atomically to outputPath. This JSON is consumed by Flipt's OPA
+// authorization policy to determine which GitHub teams can write to which
+// namespaces in each environment.
+//
+// Grants past their expires timestamp are left out, so temporary access lapses
+// on the next generation after it expires.
+//
+// Branch environments found through branches get the default environment's
+// ACLs as they stand on that branch; the policy decides how far to trust them.
+//
+// Unreadable or writer-less access files are normally logged and skipped, and
+// an unreadable acl-config.yml replaced with DefaultConfig. In strict mode
+// either leaves the existing outputPath untouched, records the broken config
+// and the namespaces that would have lost access in a failure state file next
+// to it, and returns ErrStrictFailure.
+//
+// The generated data is returned so callers can report on what was written.
+// Cancelling ctx abandons a generation that hasn't started writing yet; one
+// that has is always finished, so outputPath is never left half-written.
+func Generate(ctx context.Context, logger *zap.Logger, flagsDir string, outputPath string, msg string, strict bool, branches BranchSource) (Data, error) {
+	// A broken config is treated like a broken access file: strict mode holds
+	// the last known-good data, otherwise the defaults apply.
+	var configFailure *ConfigFailure
+	config, err := LoadConfig(flagsDir)
+	if err != nil {
+		configPath := filepath.Join(flagsDir, "acl-config.yml")
+		logger.Warn("failed to load ACL config, using defaults", zap.String("path", configPath), zap.Error(err))
+		configFailure = &ConfigFailure{Path: configPath, Reason: err.Error()}
+		config = DefaultConfig
+	}
+	aliases := config.Aliases()
+
+	now := time.Now()
+
+	result, failures, err := Collect(ctx, logger, flagsDir, aliases, now)
+	if err != nil {
+		return Data{}, err
+	}
+
+	result.SchemaVersion = SchemaVersion
+	result.SourceRevision = GitRevision(flagsDir)
+	result.AuthzConfig = config.AuthzConfig(os.Getenv("FLIPT_DEFAULT_ENVIRONMENT"))
+
+	if branches.Enabled() {
+		result.BranchTeamAccess, result.BranchRoleAccess, err = collectBranchAccess(ctx, logger, flagsDir, branches, aliases, result.AuthzConfig.DefaultEnvironment, now)
+		if err != nil {
+			return Data{}, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Data{}, err
+	}
+
+	if strict && (configFailure != nil || len(failures) > 0) {
+		if configFailure != nil {
+			logger.Error("ACL config failed to load, keeping last known-good ACL data",
+				zap.String("path", configFailure.Path),
+				zap.String("reason", configFailure.Reason),
+			)
+		}
+		for _, f := range failures {
+			logger.Error("namespace would lose access, keeping last known-good ACL data",
+				zap.String("environment", f.Environment),
+				zap.String("namespace", f.Namespace),
+				zap.String("path", f.Path),
+				zap.String("reason", f.Reason),
+			)
+		}
+
+		state, _ := json.MarshalIndent(failureState{FailedAt: time.Now().UTC(), Config: configFailure, Failures: failures}, "", "  ")
+		if err := os.WriteFile(failureStatePath(outputPath), append(state, '\n'), 0644); err != nil {
+			logger.Error("failed to write failure state", zap.String("path", failureStatePath(outputPath)), zap.Error(err))
+		}
+
+		return Data{}, ErrStrictFailure
+	}
+
+	// Keep the existing file, and its generated_at, when nothing else has
+	// changed, so identical inputs give identical output and Flipt isn't
+	// made to reload data every poll.
+	existing, _ := os.ReadFile(outputPath)
+
+	var current Data
+	if json.Unmarshal(existing, &current) == nil {
+		result.GeneratedAt = current.GeneratedAt
+	}
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	out = append(out, '\n')
+
+	if !bytes.Equal(out, existing) {
+		result.GeneratedAt = time.Now().UTC().Truncate(time.Second)
+		out, _ = json.MarshalIndent(result, "", "  ")
+
+		tmpPath := outputPath + ".tmp"
+
+		if err := os.WriteFile(tmpPath, append(out, '\n'), 0644); err != nil {
+			return Data{}, err
+		}
+
+		if err := os.Rename(tmpPath, outputPath); err != nil {
+			return Data{}, err
+		}
+	}
+
+	if err := os.Remove(failureStatePath(outputPath)); err == nil {
+		logger.Info("access files recovered, cleared failure state", zap.String("path", failureStatePath(outputPath)))
+	}
+
+	logger.Info(msg, zap.String("path", outputPath))
+	return result, nil
+}
@@ -0,0 +1,150 @@
+package acl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGenerate(t *testing.T) {
+	const good = "writers:\n    - team-a\n"
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		strict   bool
+		err      error
+		kept     bool // the previous output survives
+		teams    map[string]map[string][]string
+		config   bool // the failure state records a broken acl-config.yml
+		failures []Failure
+	}{
+		{
+			name:  "writes the data",
+			files: map[string]string{"dev/a/access.yml": good},
+			teams: map[string]map[string][]string{"dev": {"a": {"team-a"}}},
+		},
+		{
+			name:  "broken access file skipped",
+			files: map[string]string{"dev/a/access.yml": good, "dev/b/access.yml": "writers: [\n"},
+			teams: map[string]map[string][]string{"dev": {"a": {"team-a"}}},
+		},
+		{
+			name:   "strict keeps the last good data",
+			files:  map[string]string{"dev/a/access.yml": good, "dev/b/access.yml": "readers:\n    - team-b\n"},
+			strict: true,
+			err:    ErrStrictFailure,
+			kept:   true,
+			failures: []Failure{
+				{Environment: "dev", Namespace: "b", Path: "dev/b/access.yml", Reason: "no writers"},
+			},
+		},
+		{
+			name:  "broken config falls back to the defaults",
+			files: map[string]string{"dev/a/access.yml": good, "acl-config.yml": "adminTeams: [\n"},
+			teams: map[string]map[string][]string{"dev": {"a": {"team-a"}}},
+		},
+		{
+			name:   "strict keeps the last good data for a broken config",
+			files:  map[string]string{"dev/a/access.yml": good, "acl-config.yml": "adminTeams: [\n"},
+			strict: true,
+			err:    ErrStrictFailure,
+			kept:   true,
+			config: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagsDir := writeTree(t, tt.files)
+			outputPath := filepath.Join(t.TempDir(), "acl-data.json")
+			if err := os.WriteFile(outputPath, []byte("previous\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Generate(context.Background(), zap.NewNop(), flagsDir, outputPath, "generated", tt.strict, BranchSource{})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			out, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept := string(out) == "previous\n"; kept != tt.kept {
+				t.Fatalf("previous output kept: got %t, want %t", kept, tt.kept)
+			}
+			if !tt.kept {
+				var data Data
+				if err := json.Unmarshal(out, &data); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(data.NamespaceTeamAccess, tt.teams) {
+					t.Errorf("team access: got %v, want %v", data.NamespaceTeamAccess, tt.teams)
+				}
+			}
+
+			stateData, err := os.ReadFile(failureStatePath(outputPath))
+			if len(tt.failures) == 0 && !tt.config {
+				if err == nil {
+					t.Errorf("unexpected failure state: %s", stateData)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var state failureState
+			if err := json.Unmarshal(stateData, &state); err != nil {
+				t.Fatal(err)
+			}
+			for i := range state.Failures {
+				state.Failures[i].Path, _ = filepath.Rel(flagsDir, state.Failures[i].Path)
+			}
+			if !reflect.DeepEqual(state.Failures, tt.failures) {
+				t.Errorf("failures: got %+v, want %+v", state.Failures, tt.failures)
+			}
+			if got := state.Config != nil && state.Config.Path == filepath.Join(flagsDir, "acl-config.yml") && state.Config.Reason != ""; got != tt.config {
+				t.Errorf("config failure: got %+v, want one: %t", state.Config, tt.config)
+			}
+		})
+	}
+}
+
+func TestGenerateRecovers(t *testing.T) {
+	flagsDir := writeTree(t, map[string]string{"dev/a/access.yml": "writers: [\n"})
+	outputPath := filepath.Join(t.TempDir(), "acl-data.json")
+
+	if _, err := Generate(context.Background(), zap.NewNop(), flagsDir, outputPath, "generated", true, BranchSource{}); !errors.Is(err, ErrStrictFailure) {
+		t.Fatalf("got error %v, want %v", err, ErrStrictFailure)
+	}
+	if _, err := os.Stat(failureStatePath(outputPath)); err != nil {
+		t.Fatalf("no failure state: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(flagsDir, "dev", "a", "access.yml"), []byte("writers:\n    - team-a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(context.Background(), zap.NewNop(), flagsDir, outputPath, "generated", true, BranchSource{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(failureStatePath(outputPath)); !os.IsNotExist(err) {
+		t.Errorf("failure state not cleared: %v", err)
+	}
+
+	// Unchanged inputs leave the file, generated_at included, as it was.
+	first, _ := os.ReadFile(outputPath)
+	if _, err := Generate(context.Background(), zap.NewNop(), flagsDir, outputPath, "generated", true, BranchSource{}); err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := os.ReadFile(outputPath); string(second) != string(first) {
+		t.Errorf("regenerating unchanged inputs rewrote the file:\n%s\nthen:\n%s", first, second)
+	}
+}
@@ -0,0 +1,86 @@
+package acl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitRevision returns the commit checked out in the git repository containing
+// dir, read straight from .git so no git binary is needed, or "" if dir isn't
+// in a repository.
+func GitRevision(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			// Worktrees and submodules have a .git file pointing elsewhere.
+			if !info.IsDir() {
+				data, err := os.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			return resolveGitHead(gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// resolveGitHead follows HEAD to a commit hash via loose or packed refs.
+func resolveGitHead(gitDir string) string {
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	ref, isRef := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: ")
+	if !isRef {
+		return ref
+	}
+
+	// Linked worktrees keep shared refs in the common directory.
+	dirs := []string{gitDir}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		dirs = append(dirs, commonDir)
+	}
+
+	for _, dir := range dirs {
+		if data, err := os.ReadFile(filepath.Join(dir, ref)); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+
+		packed, err := os.Open(filepath.Join(dir, "packed-refs"))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(packed)
+		for scanner.Scan() {
+			if hash, name, ok := strings.Cut(scanner.Text(), " "); ok && name == ref {
+				packed.Close()
+				return hash
+			}
+		}
+		packed.Close()
+	}
+
+	return ""
+}
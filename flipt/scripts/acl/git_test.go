@@ -0,0 +1,71 @@
+package acl
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGitRevision(t *testing.T) {
+	const (
+		loose  = "1111111111111111111111111111111111111111"
+		packed = "2222222222222222222222222222222222222222"
+		common = "3333333333333333333333333333333333333333"
+	)
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		dir   string // looked up from, relative to the tree
+		want  string
+	}{
+		{
+			name:  "loose ref from a subdirectory",
+			files: map[string]string{".git/HEAD": "ref: refs/heads/main\n", ".git/refs/heads/main": loose + "\n", "flags/dev/flipt.yml": ""},
+			dir:   "flags/dev",
+			want:  loose,
+		},
+		{
+			name:  "packed ref",
+			files: map[string]string{".git/HEAD": "ref: refs/heads/main\n", ".git/packed-refs": "# pack-refs with: peeled\n" + packed + " refs/heads/main\n"},
+			want:  packed,
+		},
+		{
+			name:  "detached head",
+			files: map[string]string{".git/HEAD": loose + "\n"},
+			want:  loose,
+		},
+		{
+			name: "linked worktree",
+			files: map[string]string{
+				"repo/.git/refs/heads/topic":       common + "\n",
+				"repo/.git/worktrees/wt/HEAD":      "ref: refs/heads/topic\n",
+				"repo/.git/worktrees/wt/commondir": "../..\n",
+				"wt/.git":                          "gitdir: ../repo/.git/worktrees/wt\n",
+				"wt/flags/acl-config.yml":          "",
+			},
+			dir:  "wt/flags",
+			want: common,
+		},
+		{
+			name:  "not a repository",
+			files: map[string]string{"flags/acl-config.yml": ""},
+			dir:   "flags",
+			want:  "",
+		},
+		{
+			name:  "unresolvable ref",
+			files: map[string]string{".git/HEAD": "ref: refs/heads/missing\n"},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+
+			if got := GitRevision(filepath.Join(root, tt.dir)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats tracks generation outcomes for a watcher's /healthz and /metrics
+// endpoints.
+type Stats struct {
+	// StaleAfter is how long after the last successful generation Healthz
+	// starts reporting unhealthy.
+	StaleAfter time.Duration
+
+	mu          sync.Mutex
+	generations int
+	errors      int
+	lastSuccess time.Time
+	lastError   string
+	duration    time.Duration
+	namespaces  map[string]int
+}
+
+// Observe runs a generation, timing it and recording the outcome.
+func (s *Stats) Observe(generateFn func() (Data, error)) (Data, error) {
+	start := time.Now()
+	result, err := generateFn()
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generations++
+	s.duration = elapsed
+
+	if err != nil {
+		s.errors++
+		s.lastError = err.Error()
+		return result, err
+	}
+
+	s.lastSuccess = time.Now()
+	s.lastError = ""
+	s.namespaces = make(map[string]int, len(result.NamespaceTeamAccess))
+	for environment, namespaces := range result.NamespaceTeamAccess {
+		s.namespaces[environment] = len(namespaces)
+	}
+
+	return result, nil
+}
+
+// Idle records a tick with no flags to generate from as up to date, so an
+// empty tree doesn't make a working watcher look stale.
+func (s *Stats) Idle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSuccess = time.Now()
+	s.lastError = ""
+}
+
+// Healthz reports 200 while the last successful generation is within the
+// staleness threshold, and 503 otherwise.
+func (s *Stats) Healthz(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body := struct {
+		Status             string     `json:"status"`
+		LastSuccess        *time.Time `json:"last_success,omitempty"`
+		LastError          string     `json:"last_error,omitempty"`
+		StalenessThreshold string     `json:"staleness_threshold"`
+	}{
+		Status:             "ok",
+		LastError:          s.lastError,
+		StalenessThreshold: s.StaleAfter.String(),
+	}
+
+	status := http.StatusOK
+	if s.lastSuccess.IsZero() || time.Since(s.lastSuccess) > s.StaleAfter {
+		body.Status = "stale"
+		status = http.StatusServiceUnavailable
+	}
+	if !s.lastSuccess.IsZero() {
+		lastSuccess := s.lastSuccess.UTC()
+		body.LastSuccess = &lastSuccess
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Metrics writes the stats in the Prometheus text exposition format.
+func (s *Stats) Metrics(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP flipt_acl_generations_total ACL data generation attempts.")
+	fmt.Fprintln(w, "# TYPE flipt_acl_generations_total counter")
+	fmt.Fprintf(w, "flipt_acl_generations_total %d\n", s.generations)
+
+	fmt.Fprintln(w, "# HELP flipt_acl_generation_errors_total ACL data generation attempts that failed.")
+	fmt.Fprintln(w, "# TYPE flipt_acl_generation_errors_total counter")
+	fmt.Fprintf(w, "flipt_acl_generation_errors_total %d\n", s.errors)
+
+	fmt.Fprintln(w, "# HELP flipt_acl_generation_duration_seconds Duration of the last ACL data generation.")
+	fmt.Fprintln(w, "# TYPE flipt_acl_generation_duration_seconds gauge")
+	fmt.Fprintf(w, "flipt_acl_generation_duration_seconds %g\n", s.duration.Seconds())
+
+	fmt.Fprintln(w, "# HELP flipt_acl_last_success_timestamp_seconds Unix time of the last successful ACL data generation.")
+	fmt.Fprintln(w, "# TYPE flipt_acl_last_success_timestamp_seconds gauge")
+	lastSuccess := 0.0
+	if !s.lastSuccess.IsZero() {
+		lastSuccess = float64(s.lastSuccess.UnixNano()) / 1e9
+	}
+	fmt.Fprintf(w, "flipt_acl_last_success_timestamp_seconds %g\n", lastSuccess)
+
+	fmt.Fprintln(w, "# HELP flipt_acl_namespaces Namespaces with ACL entries, per environment.")
+	fmt.Fprintln(w, "# TYPE flipt_acl_namespaces gauge")
+	environments := make([]string, 0, len(s.namespaces))
+	for environment := range s.namespaces {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+	for _, environment := range environments {
+		fmt.Fprintf(w, "flipt_acl_namespaces{environment=%q} %d\n", environment, s.namespaces[environment])
+	}
+}
@@ -0,0 +1,112 @@
+package acl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsHealthz(t *testing.T) {
+	generated := func() (Data, error) { return Data{}, nil }
+	failed := func() (Data, error) { return Data{}, errors.New("boom") }
+
+	tests := []struct {
+		name      string
+		run       func(s *Stats)
+		status    int
+		body      string
+		lastError string
+	}{
+		{
+			name:   "nothing generated yet",
+			run:    func(*Stats) {},
+			status: http.StatusServiceUnavailable,
+			body:   "stale",
+		},
+		{
+			name:   "generated",
+			run:    func(s *Stats) { s.Observe(generated) },
+			status: http.StatusOK,
+			body:   "ok",
+		},
+		{
+			name:      "failure after a success stays healthy until stale",
+			run:       func(s *Stats) { s.Observe(generated); s.Observe(failed) },
+			status:    http.StatusOK,
+			body:      "ok",
+			lastError: "boom",
+		},
+		{
+			name:   "idle counts as up to date",
+			run:    func(s *Stats) { s.Idle() },
+			status: http.StatusOK,
+			body:   "ok",
+		},
+		{
+			name: "stale",
+			run: func(s *Stats) {
+				s.Observe(generated)
+				s.lastSuccess = time.Now().Add(-time.Hour)
+			},
+			status: http.StatusServiceUnavailable,
+			body:   "stale",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Stats{StaleAfter: time.Minute}
+			tt.run(s)
+
+			rec := httptest.NewRecorder()
+			s.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status: got %d, want %d", rec.Code, tt.status)
+			}
+
+			var body struct {
+				Status    string `json:"status"`
+				LastError string `json:"last_error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Status != tt.body || body.LastError != tt.lastError {
+				t.Errorf("body: got %+v, want status %q, last_error %q", body, tt.body, tt.lastError)
+			}
+		})
+	}
+}
+
+func TestStatsMetrics(t *testing.T) {
+	s := &Stats{StaleAfter: time.Minute}
+	s.Observe(func() (Data, error) {
+		return Data{NamespaceTeamAccess: map[string]map[string][]string{
+			"prod": {"a": {"team-a"}},
+			"dev":  {"a": {"team-a"}, "b": {"team-b"}},
+		}}, nil
+	})
+	s.Observe(func() (Data, error) { return Data{}, errors.New("boom") })
+
+	rec := httptest.NewRecorder()
+	s.Metrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"flipt_acl_generations_total 2\n",
+		"flipt_acl_generation_errors_total 1\n",
+		"flipt_acl_namespaces{environment=\"dev\"} 2\nflipt_acl_namespaces{environment=\"prod\"} 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "flipt_acl_last_success_timestamp_seconds 0\n") {
+		t.Errorf("last success not recorded:\n%s", body)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/acl"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"go.uber.org/zap"
+)
+
+// refFlag collects repeated <environment>=<ref> flag values.
+type refFlag map[string]string
+
+func (f refFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for environment, ref := range f {
+		pairs = append(pairs, environment+"="+ref)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f refFlag) Set(value string) error {
+	environment, ref, ok := strings.Cut(value, "=")
+	if !ok || environment == "" || ref == "" {
+		return fmt.Errorf("expected <environment>=<ref>, got %q", value)
+	}
+	f[environment] = ref
+	return nil
+}
+
+// aclOptions are the flags acl generate and acl watch share.
+type aclOptions struct {
+	strict          *bool
+	auditPath       *string
+	bundlePath      *string
+	policyDir       *string
+	branchRefPrefix *string
+	branchRefs      refFlag
+
+	// watch mode only
+	watch      bool
+	interval   *time.Duration
+	listen     *string
+	staleAfter *time.Duration
+}
+
+func registerACLFlags(fs *flag.FlagSet) *aclOptions {
+	opts := &aclOptions{
+		strict:          fs.Bool("strict", false, "keep the last known-good ACL data if any access file fails to parse"),
+		auditPath:       fs.String("audit-log", "", "append each ACL grant/revoke to this file as JSON lines"),
+		bundlePath:      fs.String("bundle", "", "also write an OPA bundle tarball (data, policy and manifest) to this path"),
+		policyDir:       fs.String("policy-dir", "/etc/flipt/policies", "directory of Rego policies to include in --bundle"),
+		branchRefPrefix: fs.String("branch-ref-prefix", "", "generate ACLs for every git ref under this prefix (e.g. refs/heads/flipt/), as a branch environment named after the rest of the ref"),
+		branchRefs:      make(refFlag),
+	}
+	fs.Var(opts.branchRefs, "branch-ref", "generate ACLs for a branch environment from a git ref, as <environment>=<ref> (repeatable)")
+	return opts
+}
+
+// aclGenerateCommand writes the acl-data.json Flipt's OPA policy reads (see
+// package acl) once.
+func aclGenerateCommand(fs *flag.FlagSet) runFunc {
+	opts := registerACLFlags(fs)
+
+	return func(g *globals, args []string) error {
+		return runACL(g, opts, args)
+	}
+}
+
+// aclWatchCommand keeps acl-data.json up to date, regenerating it on every
+// poll so both file changes and expiring grants are picked up.
+func aclWatchCommand(fs *flag.FlagSet) runFunc {
+	opts := registerACLFlags(fs)
+	opts.watch = true
+	opts.interval = fs.Duration("interval", 15*time.Second, "poll interval")
+	opts.listen = fs.String("listen", "", "address to serve /healthz and /metrics on (e.g. :9102)")
+	opts.staleAfter = fs.Duration("stale-after", 5*time.Minute, "report unhealthy when the last successful generation is older than this")
+
+	return func(g *globals, args []string) error {
+		return runACL(g, opts, args)
+	}
+}
+
+// runACL generates the ACL data to the output path in args, then in watch
+// mode keeps regenerating it until interrupted.
+func runACL(g *globals, opts *aclOptions, args []string) error {
+	if len(args) != 1 {
+		return usageError("expected one output path")
+	}
+
+	logger := g.logger
+	flagsDir := g.flagsDir
+	outputPath := args[0]
+	// SIGTERM/SIGINT cancel ctx: any write in progress finishes, then the
+	// watcher exits. SIGHUP regenerates straight away, e.g. after a git pull.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	stats := &acl.Stats{}
+	if opts.watch {
+		stats.StaleAfter = *opts.staleAfter
+	}
+
+	if opts.watch && *opts.listen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", stats.Healthz)
+		mux.HandleFunc("/metrics", stats.Metrics)
+
+		server := &http.Server{Addr: *opts.listen, Handler: mux}
+
+		go func() {
+			logger.Info("serving health and metrics", zap.String("address", *opts.listen))
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("health and metrics server stopped", zap.Error(err))
+			}
+		}()
+
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// Seed the audit baseline from any data already on disk (e.g. baked into
+	// the image), so the first generation's changes are audited too.
+	var previous *acl.Data
+	if existing, err := os.ReadFile(outputPath); err == nil {
+		var data acl.Data
+		if json.Unmarshal(existing, &data) == nil {
+			previous = &data
+		}
+	}
+
+	// The cache saves a watcher re-extracting every branch on every poll.
+	branches := acl.BranchSource{Prefix: *opts.branchRefPrefix, Refs: opts.branchRefs, Cache: &acl.BranchCache{}}
+	defer branches.Cache.Close()
+
+	// The bundle is only rewritten when the data in it would change, so OPA
+	// isn't made to reload an identical bundle every poll.
+	var bundled *acl.Data
+
+	run := func(msg string) error {
+		result, err := stats.Observe(func() (acl.Data, error) {
+			return acl.Generate(ctx, logger, flagsDir, outputPath, msg, *opts.strict, branches)
+		})
+		if err != nil {
+			return err
+		}
+
+		if previous != nil {
+			acl.Audit(logger, acl.Diff(*previous, result, time.Now().UTC()), *opts.auditPath)
+		}
+		previous = &result
+
+		if *opts.bundlePath != "" && (bundled == nil || !reflect.DeepEqual(*bundled, result)) {
+			if err := acl.WriteBundle(*opts.bundlePath, *opts.policyDir, result, result.SourceRevision); err != nil {
+				return fmt.Errorf("writing bundle: %w", err)
+			}
+			logger.Info("wrote OPA bundle", zap.String("path", *opts.bundlePath), zap.String("revision", result.SourceRevision))
+			bundled = &result
+		}
+
+		return nil
+	}
+
+	if err := run("generated ACL data"); errors.Is(err, context.Canceled) {
+		logger.Info("interrupted, exiting")
+		return nil
+	} else if err != nil {
+		// A watcher with last known-good data to serve keeps running so it can
+		// pick up the fix; anything else has nothing safe to fall back on.
+		if _, statErr := os.Stat(outputPath); !opts.watch || !errors.Is(err, acl.ErrStrictFailure) || statErr != nil {
+			logger.Fatal("failed to generate ACL data", zap.Error(err))
+		}
+		logger.Error("failed to generate ACL data", zap.Error(err))
+	}
+
+	if !opts.watch {
+		return nil
+	}
+
+	logger.Info("polling for changes", zap.String("path", flagsDir), zap.Duration("interval", *opts.interval))
+
+	var lastOutput []byte
+
+	ticker := time.NewTicker(*opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("shutting down")
+			return nil
+		case <-reload:
+			logger.Info("received SIGHUP, regenerating ACL data")
+		case <-ticker.C:
+		}
+
+		current, err := os.ReadFile(outputPath)
+		if err != nil {
+			logger.Warn("failed to read current ACL data", zap.Error(err))
+		}
+
+		// Regenerate on every tick, not just on file changes, so grants
+		// lapse as soon as they expire.
+		if len(flagfile.NamespaceDirs(flagsDir)) == 0 {
+			stats.Idle()
+			continue
+		}
+
+		if err := run("refreshed ACL data"); errors.Is(err, context.Canceled) {
+			continue
+		} else if err != nil {
+			logger.Error("failed to regenerate ACL data", zap.Error(err))
+			continue
+		}
+
+		newOutput, _ := os.ReadFile(outputPath)
+
+		if lastOutput == nil {
+			lastOutput = current
+		}
+
+		if string(newOutput) != string(lastOutput) {
+			logger.Info("ACL data changed, written to disk", zap.String("path", outputPath))
+		}
+
+		lastOutput = newOutput
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// shells are the shells flagctl completion writes scripts for.
+var shells = []string{"bash", "zsh", "fish"}
+
+// The completion scripts hand the words typed so far to the hidden
+// `flagctl __complete` and offer what it prints, falling back to file names
+// when it prints nothing (paths, and values flagctl can't guess).
+var completionScripts = map[string]string{
+	"bash": `# bash completion for flagctl
+# Add to ~/.bashrc: source <(flagctl completion bash)
+_flagctl() {
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(flagctl __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)" -- "${COMP_WORDS[COMP_CWORD]}"))
+    if [ ${#COMPREPLY[@]} -eq 0 ]; then
+        compopt -o default
+    fi
+}
+complete -F _flagctl flagctl
+`,
+	"zsh": `#compdef flagctl
+# zsh completion for flagctl
+# Add to ~/.zshrc: source <(flagctl completion zsh)
+_flagctl() {
+    local -a candidates
+    candidates=("${(@f)$(flagctl __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -n ${candidates[1]} ]]; then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+compdef _flagctl flagctl
+`,
+	"fish": `# fish completion for flagctl
+# Add to ~/.config/fish/config.fish: flagctl completion fish | source
+function __flagctl_complete
+    set -l candidates (flagctl __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)
+    test (count $candidates) -gt 0; and printf '%s\n' $candidates
+end
+complete -c flagctl -f -n '__flagctl_complete >/dev/null' -a '(__flagctl_complete)'
+complete -c flagctl -F -n 'not __flagctl_complete >/dev/null'
+`,
+}
+
+func completionCommand(fs *flag.FlagSet) runFunc {
+	return func(g *globals, args []string) error {
+		if len(args) != 1 {
+			return usageError("expected one shell: " + strings.Join(shells, ", "))
+		}
+
+		script, ok := completionScripts[args[0]]
+		if !ok {
+			return usageError(fmt.Sprintf("unknown shell %q (expected one of: %s)", args[0], strings.Join(shells, ", ")))
+		}
+
+		fmt.Print(script)
+		return nil
+	}
+}
+
+// complete returns the completions for the last of words, the arguments typed
+// after flagctl so far: subcommands, flag names, or the values a flag or
+// argument takes. It returns nothing where a file name is wanted.
+func complete(g *globals, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	cmd := &command{subcommands: commands}
+	var path []string
+	fs, _ := newFlagSet(g, "", cmd)
+
+	// The flag the previous word left waiting for a value, if any.
+	var pending string
+
+	for _, word := range words[:len(words)-1] {
+		if pending != "" {
+			fs.Set(pending, word)
+			pending = ""
+			continue
+		}
+
+		if strings.HasPrefix(word, "-") {
+			name := strings.TrimLeft(word, "-")
+			if name, value, hasValue := strings.Cut(name, "="); hasValue {
+				fs.Set(name, value)
+			} else if f := fs.Lookup(name); f != nil && !isBoolFlag(f) {
+				pending = name
+			}
+			continue
+		}
+
+		if i := slices.IndexFunc(cmd.subcommands, func(c *command) bool { return c.name == word }); i >= 0 {
+			cmd = cmd.subcommands[i]
+			path = append(path, cmd.name)
+			fs, _ = newFlagSet(g, strings.Join(path, " "), cmd)
+		}
+	}
+
+	var candidates []string
+	switch {
+	case pending != "":
+		candidates = flagValues(g, cmd, pending, "")
+	case strings.HasPrefix(current, "-"):
+		name := strings.TrimLeft(current, "-")
+		if name, _, hasValue := strings.Cut(name, "="); hasValue {
+			candidates = flagValues(g, cmd, name, "--"+name+"=")
+			break
+		}
+		fs.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "--"+f.Name)
+		})
+	case len(cmd.subcommands) > 0:
+		for _, sub := range cmd.subcommands {
+			candidates = append(candidates, sub.name)
+		}
+	case cmd.argValues != nil:
+		candidates = cmd.argValues(g)
+	}
+
+	return slices.DeleteFunc(candidates, func(c string) bool { return !strings.HasPrefix(c, current) })
+}
+
+// flagValues returns the known values of cmd's (or a global) flag name, each
+// prefixed with prefix.
+func flagValues(g *globals, cmd *command, name, prefix string) []string {
+	values, ok := cmd.flagValues[name]
+	if !ok {
+		values, ok = globalValues[name]
+	}
+	if !ok {
+		return nil
+	}
+
+	var candidates []string
+	for _, value := range values(g) {
+		candidates = append(candidates, prefix+value)
+	}
+	return candidates
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/internal/cli"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/lint"
+	"gopkg.in/yaml.v3"
+)
+
+var flagRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// namespaceEnvironments lists the environments (directories with a flipt.yml)
+// the namespace exists in.
+func namespaceEnvironments(flagsDir, namespace string) []string {
+	var envs []string
+	for _, env := range flagfile.Environments(flagsDir) {
+		if fi, err := os.Stat(filepath.Join(flagsDir, env, namespace)); err == nil && fi.IsDir() {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// readFeatures returns the flag and segment keys already in the features
+// files at paths.
+func readFeatures(paths ...string) (flagKeys, segmentKeys []string, err error) {
+	for _, path := range paths {
+		file, err := flagfile.LoadFeatures(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, f := range file.Flags {
+			flagKeys = append(flagKeys, f.Key)
+		}
+		for _, s := range file.Segments {
+			segmentKeys = append(segmentKeys, s.Key)
+		}
+	}
+
+	return flagKeys, segmentKeys, nil
+}
+
+// targetFile picks which of a namespace's features files a new flag goes in:
+// features.yml (or .yaml) if there is one, otherwise the first.
+func targetFile(files []string) string {
+	for _, path := range files {
+		if name := filepath.Base(path); name == "features.yml" || name == "features.yaml" {
+			return path
+		}
+	}
+	return files[0]
+}
+
+// flagNewCommand adds a flag to a namespace in every environment it's in,
+// prompting for anything not given on the command line.
+func flagNewCommand(fs *flag.FlagSet) runFunc {
+	var opts flagOptions
+	fs.StringVar(&opts.namespace, "namespace", "", "namespace to add the flag to")
+	fs.StringVar(&opts.key, "key", "", "flag key")
+	fs.StringVar(&opts.name, "name", "", "display name (defaults to the key)")
+	fs.StringVar(&opts.description, "description", "", "description")
+	fs.StringVar(&opts.flagType, "type", "", "boolean or variant")
+	fs.Var(&opts.variants, "variant", "variant key for a variant flag, the first is the default (repeatable)")
+	fs.Var(&opts.envs, "env", "environment to create the flag in (repeatable, defaults to every environment the namespace is in)")
+	fs.StringVar(&opts.segment, "segment", "", "existing segment to roll the flag out to")
+	fs.BoolVar(&opts.enabled, "enabled", false, "create the flag enabled outside the read-only environments (readOnlyEnvironments in acl-config.yml)")
+	fs.BoolVar(&opts.enabledInProd, "enabled-in-prod", false, "create the flag enabled in the read-only environments (e.g. prod) too")
+	fs.BoolVar(&opts.yes, "yes", false, "don't prompt: missing optional values use their defaults, and the summary isn't confirmed")
+
+	return func(g *globals, args []string) error {
+		if len(args) > 0 {
+			return usageError("unexpected arguments: " + strings.Join(args, " "))
+		}
+
+		newFlag(g.flagsDir, opts)
+		return nil
+	}
+}
+
+// flagOptions are the flag new flags.
+type flagOptions struct {
+	namespace, key, name, description string
+	flagType                          string
+	variants, envs                    cli.ListFlag
+	segment                           string
+	enabled, enabledInProd            bool
+	yes                               bool
+}
+
+// newFlag runs the flag new wizard, exiting with one of the exit codes in
+// main.go if the flag can't be created.
+func newFlag(flagsDir string, opts flagOptions) {
+	cfg := loadACLConfig(flagsDir)
+
+	// Explicit flags skip their prompts; --yes skips the rest, so a required
+	// value missing then is an error rather than a prompt nobody will answer.
+	if opts.yes && (opts.namespace == "" || opts.key == "") {
+		cli.Exit(exitMissingInput, "--namespace and --key are required with --yes.")
+	}
+
+	fmt.Println()
+	cli.Info("============================================")
+	cli.Info("  Create a new Flipt flag")
+	cli.Info("============================================")
+	fmt.Println()
+
+	// --- Gather inputs ---
+
+	namespace := opts.namespace
+	if namespace == "" {
+		namespace = cli.Prompt("Namespace", "")
+	}
+
+	envs := namespaceEnvironments(flagsDir, namespace)
+	if len(envs) == 0 {
+		cli.Exit(exitInvalidEnvironment, fmt.Sprintf("Namespace '%s' doesn't exist in any environment.", namespace))
+	}
+
+	selectedEnvs := envs
+	if len(opts.envs) > 0 {
+		selectedEnvs = nil
+		for _, env := range opts.envs {
+			if !slices.Contains(envs, env) {
+				cli.Exit(exitInvalidEnvironment, fmt.Sprintf("Namespace '%s' isn't in environment '%s' (it's in: %s).", namespace, env, strings.Join(envs, ", ")))
+			}
+			if !slices.Contains(selectedEnvs, env) {
+				selectedEnvs = append(selectedEnvs, env)
+			}
+		}
+		sort.Strings(selectedEnvs)
+	}
+
+	key := opts.key
+	if key == "" {
+		key = cli.Prompt("Flag key (e.g. my-new-feature)", "")
+	}
+	if !flagRegex.MatchString(key) {
+		cli.Exit(exitInvalidKey, "Flag key must be letters, numbers, hyphens and underscores.")
+	}
+
+	name := opts.name
+	if name == "" && opts.yes {
+		name = key
+	} else if name == "" {
+		name = cli.Prompt("Display name", key)
+	}
+
+	description := opts.description
+	if description == "" && !opts.yes {
+		description = cli.OptionalPrompt("Description (optional)")
+	}
+
+	flagType := strings.ToLower(opts.flagType)
+	if flagType == "" && opts.yes {
+		flagType = "boolean"
+	} else if flagType == "" {
+		flagType = strings.ToLower(cli.Prompt("Type (boolean or variant)", "boolean"))
+	}
+	if flagType != "boolean" && flagType != "variant" {
+		cli.Exit(exitInvalidType, fmt.Sprintf("Unknown flag type '%s' (expected boolean or variant).", flagType))
+	}
+
+	variants := []string(opts.variants)
+	if flagType == "variant" && len(variants) == 0 {
+		if opts.yes {
+			cli.Exit(exitMissingInput, "A variant flag needs at least one --variant with --yes.")
+		}
+		fmt.Println()
+		variants = cli.PromptList("Variant keys (the first is the default):")
+	}
+	if flagType == "boolean" && len(variants) > 0 {
+		cli.Exit(exitInvalidType, "Boolean flags can't have variants.")
+	}
+	for _, v := range variants {
+		if !flagRegex.MatchString(v) {
+			cli.Exit(exitInvalidKey, fmt.Sprintf("Variant key '%s' must be letters, numbers, hyphens and underscores.", v))
+		}
+	}
+
+	segment := opts.segment
+	if segment == "" && !opts.yes {
+		segment = cli.OptionalPrompt("Segment to roll out to (optional)")
+	}
+
+	// --- Check every environment can take the flag ---
+
+	// Flipt reads every features file in the namespace, so a key in any of
+	// them is taken and a segment in any of them can be used.
+	targets := make(map[string]string)
+	for _, env := range selectedEnvs {
+		nsDir := filepath.Join(flagsDir, env, namespace)
+		files := flagfile.FeaturesFiles(nsDir)
+		if len(files) == 0 {
+			cli.Exit(1, fmt.Sprintf("No features file in %s.", nsDir))
+		}
+		targets[env] = targetFile(files)
+
+		flagKeys, segmentKeys, err := readFeatures(files...)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to read %v", err))
+		}
+
+		if slices.Contains(flagKeys, key) {
+			cli.Exit(exitFlagExists, fmt.Sprintf("Flag '%s' already exists in %s/%s!", key, env, namespace))
+		}
+		if segment != "" && !slices.Contains(segmentKeys, segment) {
+			cli.Exit(exitInvalidSegment, fmt.Sprintf("Segment '%s' isn't defined in %s/%s.", segment, env, namespace))
+		}
+	}
+
+	// --- Summary ---
+
+	fmt.Println()
+	cli.Info("============================================")
+	cli.Info("  Summary")
+	cli.Info("============================================")
+	fmt.Println()
+	fmt.Printf("  %sNamespace:%s    %s\n", cli.Bold, cli.Reset, namespace)
+	fmt.Printf("  %sKey:%s          %s\n", cli.Bold, cli.Reset, key)
+	fmt.Printf("  %sName:%s         %s\n", cli.Bold, cli.Reset, name)
+	if description != "" {
+		fmt.Printf("  %sDescription:%s  %s\n", cli.Bold, cli.Reset, description)
+	}
+	fmt.Printf("  %sType:%s         %s\n", cli.Bold, cli.Reset, flagType)
+	if len(variants) > 0 {
+		fmt.Printf("  %sVariants:%s     %s\n", cli.Bold, cli.Reset, strings.Join(variants, ", "))
+	}
+	if segment != "" {
+		fmt.Printf("  %sSegment:%s      %s\n", cli.Bold, cli.Reset, segment)
+	}
+	fmt.Printf("  %sEnvironments:%s %s\n", cli.Bold, cli.Reset, strings.Join(selectedEnvs, ", "))
+	fmt.Printf("  %sEnabled:%s      %t (read-only environments: %t)\n", cli.Bold, cli.Reset, opts.enabled, opts.enabledInProd)
+	fmt.Println()
+
+	if !opts.yes && !cli.Confirm("Create this flag? [Y/n]:", true) {
+		cli.Warn("Aborted.")
+		return
+	}
+
+	// --- Generate and check files ---
+
+	updated := make(map[string][]byte)
+	for _, env := range selectedEnvs {
+		f := flagfile.Flag{
+			Key:         key,
+			Name:        name,
+			Type:        "BOOLEAN_FLAG_TYPE",
+			Description: description,
+			Enabled:     opts.enabled,
+		}
+		// New flags start disabled in read-only environments (prod) unless
+		// asked for explicitly.
+		if cfg.ReadOnly(env) {
+			f.Enabled = opts.enabledInProd
+		}
+
+		if flagType == "variant" {
+			f.Type = "VARIANT_FLAG_TYPE"
+			for i, v := range variants {
+				f.Variants = append(f.Variants, flagfile.Variant{Default: i == 0, Key: v})
+			}
+			if segment != "" {
+				f.Rules = []flagfile.Rule{{
+					Segment:       &flagfile.SegmentRef{Key: segment},
+					Distributions: []flagfile.Distribution{{Variant: variants[0], Rollout: 100}},
+				}}
+			}
+		} else if segment != "" {
+			f.Rollouts = []flagfile.Rollout{{Segment: &flagfile.SegmentRef{Key: segment, Value: true}}}
+		}
+
+		path := targets[env]
+		rel, _ := filepath.Rel(flagsDir, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to read %s: %v", path, err))
+		}
+
+		out, err := flagfile.AppendFlag(data, f)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to add the flag to %s: %v", path, err))
+		}
+
+		// The file must pass flagctl lint's checks (so a duplicate or invalid
+		// variant key is caught here), and the flag must read back exactly
+		// once, alongside everything that was already there.
+		var problems []string
+		for _, iss := range lint.Content(flagsDir, path, out) {
+			if iss.Level == lint.Error {
+				problems = append(problems, iss.Message)
+			}
+		}
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				cli.Fail(fmt.Sprintf("  %s: %s", filepath.ToSlash(rel), problem))
+			}
+			cli.Exit(exitLintFailed, fmt.Sprintf("Generated %s failed lint, nothing was written.", path))
+		}
+
+		flagKeys, _, err := readFeatures(path)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to read %v", err))
+		}
+		var written flagfile.Features
+		if err := yaml.Unmarshal(out, &written); err != nil || len(written.Flags) != len(flagKeys)+1 || written.Flags[len(written.Flags)-1].Key != key {
+			cli.Exit(exitLintFailed, fmt.Sprintf("Generated %s failed lint, nothing was written.", path))
+		}
+
+		updated[path] = out
+	}
+
+	// --- Write files ---
+
+	fmt.Println()
+	for _, env := range selectedEnvs {
+		path := targets[env]
+		if err := os.WriteFile(path, updated[path], 0644); err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to write %s: %v", path, err))
+		}
+		rel, _ := filepath.Rel(flagsDir, path)
+		cli.OK(fmt.Sprintf("  Added to %s", filepath.ToSlash(rel)))
+	}
+
+	fmt.Println()
+	cli.OK(fmt.Sprintf("Flag '%s' created in %s.", key, strings.Join(selectedEnvs, ", ")))
+	fmt.Println()
+	cli.Info("Next steps:")
+	cli.Info("  1. Run 'make flags-lint' to validate")
+	cli.Info("  2. Raise a PR to main")
+	fmt.Println()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/internal/cli"
+)
+
+// flagChange records one flag's enabled state before and after the edit, and
+// the file it's in.
+type flagChange struct {
+	flagfile.EnabledChange
+	path string
+}
+
+// readKeyList reads flag keys from a file, one per line. Blank lines and
+// lines starting with # are ignored.
+func readKeyList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+
+	return keys, scanner.Err()
+}
+
+// flagSetCommand enables or disables flags in one namespace of one
+// environment, printing a summary for the PR description.
+func flagSetCommand(fs *flag.FlagSet) runFunc {
+	enabledFlag := fs.String("enabled", "", "true or false (required)")
+	listFile := fs.String("file", "", "file of flag keys to set, one per line")
+
+	return func(g *globals, args []string) error {
+		if len(args) < 2 {
+			return usageError("expected <env> <namespace> [<flag-key>...]")
+		}
+
+		enabled, err := strconv.ParseBool(*enabledFlag)
+		if err != nil {
+			return usageError("--enabled=true or --enabled=false is required")
+		}
+
+		keys := args[2:]
+		if *listFile != "" {
+			listed, err := readKeyList(*listFile)
+			if err != nil {
+				cli.Exit(1, fmt.Sprintf("Failed to read %s: %v", *listFile, err))
+			}
+			keys = append(keys, listed...)
+		}
+		keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+
+		if len(keys) == 0 {
+			return usageError("no flag keys given")
+		}
+
+		setFlags(g.flagsDir, args[0], args[1], keys, enabled)
+		return nil
+	}
+}
+
+// setFlags sets enabled on keys in env/namespace and prints the summary,
+// exiting 1 without writing anything if a key isn't there.
+func setFlags(flagsDir, env, namespace string, keys []string, enabled bool) {
+	nsDir := filepath.Join(flagsDir, env, namespace)
+	if fi, err := os.Stat(nsDir); err != nil || !fi.IsDir() {
+		cli.Exit(1, fmt.Sprintf("Namespace '%s' doesn't exist in %s.", namespace, env))
+	}
+
+	files := flagfile.FeaturesFiles(nsDir)
+
+	// --- Edit in memory, so an unknown key leaves every file untouched ---
+
+	var changes []flagChange
+	updated := make(map[string][]byte)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to read %s: %v", path, err))
+		}
+
+		out, fileChanges, err := flagfile.SetEnabled(data, keys, enabled)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to update %s: %v", path, err))
+		}
+
+		for _, c := range fileChanges {
+			changes = append(changes, flagChange{c, path})
+		}
+		if !bytes.Equal(out, data) {
+			updated[path] = out
+		}
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if !slices.ContainsFunc(changes, func(c flagChange) bool { return c.Key == key }) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		cli.Exit(1, fmt.Sprintf("No such flag in %s/%s: %s", env, namespace, strings.Join(missing, ", ")))
+	}
+
+	for _, path := range files {
+		if out, ok := updated[path]; ok {
+			if err := os.WriteFile(path, out, 0644); err != nil {
+				cli.Exit(1, fmt.Sprintf("Failed to write %s: %v", path, err))
+			}
+		}
+	}
+
+	// --- Summary, ready to paste into the PR description ---
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	state := func(on bool) string {
+		if on {
+			return "enabled"
+		}
+		return "disabled"
+	}
+
+	verb := "Disable"
+	if enabled {
+		verb = "Enable"
+	}
+
+	fmt.Printf("### %s flags in `%s` / `%s`\n\n", verb, env, namespace)
+	fmt.Println("| Flag | Before | After |")
+	fmt.Println("|---|---|---|")
+	for _, c := range changes {
+		after := "**" + state(c.After) + "**"
+		if c.Before == c.After {
+			after = state(c.After) + " (unchanged)"
+		}
+		rel, _ := filepath.Rel(flagsDir, c.path)
+		fmt.Printf("| `%s` (`%s`) | %s | %s |\n", c.Key, filepath.ToSlash(rel), state(c.Before), after)
+	}
+}
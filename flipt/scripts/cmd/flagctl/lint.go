@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/lint"
+	"go.uber.org/zap"
+)
+
+// discoverFiles lists the flag files under flagsDir, warning if there are
+// none.
+func discoverFiles(logger *zap.Logger, flagsDir string, args []string) ([]string, error) {
+	if len(args) > 0 {
+		return nil, usageError("unexpected arguments: " + strings.Join(args, " "))
+	}
+
+	files := lint.DiscoverFiles(flagsDir)
+	if len(files) == 0 {
+		logger.Warn("no flag files found", zap.String("path", flagsDir))
+	}
+	return files, nil
+}
+
+// fmtCommand rewrites the files under flags/ in canonical form.
+func fmtCommand(fs *flag.FlagSet) runFunc {
+	jobs := fs.Int("jobs", runtime.NumCPU(), "number of files to format at once")
+
+	return func(g *globals, args []string) error {
+		files, err := discoverFiles(g.logger, g.flagsDir, args)
+		if err != nil {
+			return err
+		}
+
+		errs := make([]error, len(files))
+		lint.ForEachFile(files, *jobs, func(i int, path string) {
+			errs[i] = lint.Fix(path, lint.KeyOrderFor(g.flagsDir, path))
+		})
+
+		for i, path := range files {
+			rel, _ := filepath.Rel(g.flagsDir, path)
+			if rel == "" {
+				rel = path
+			}
+
+			if errs[i] != nil {
+				g.logger.Error("failed to fix file", zap.String("path", rel), zap.Error(errs[i]))
+			} else {
+				g.logger.Info("formatted", zap.String("path", rel))
+			}
+		}
+		return nil
+	}
+}
+
+// lintCommand checks the files under flags/ (see package lint), exiting 1 if
+// any has an error.
+func lintCommand(fs *flag.FlagSet) runFunc {
+	jobs := fs.Int("jobs", runtime.NumCPU(), "number of files to check at once")
+
+	return func(g *globals, args []string) error {
+		files, err := discoverFiles(g.logger, g.flagsDir, args)
+		if err != nil || len(files) == 0 {
+			return err
+		}
+
+		lintFiles(g.logger, g.flagsDir, files, *jobs)
+		return nil
+	}
+}
+
+// lintFiles checks files and reports their issues grouped by file.
+func lintFiles(logger *zap.Logger, flagsDir string, files []string, jobs int) {
+	totalErrors := 0
+	totalWarnings := 0
+	filesWithIssues := make(map[string][]lint.Issue)
+
+	for i, fileIssues := range lint.Files(flagsDir, files, jobs) {
+		rel, _ := filepath.Rel(flagsDir, files[i])
+		if rel == "" {
+			rel = files[i]
+		}
+
+		if len(fileIssues) > 0 {
+			filesWithIssues[rel] = fileIssues
+			for _, iss := range fileIssues {
+				if iss.Level == lint.Error {
+					totalErrors++
+				} else {
+					totalWarnings++
+				}
+			}
+		}
+	}
+
+	// Report — grouped by file, errors first, then warnings
+	if len(filesWithIssues) > 0 {
+		// Sort file paths for deterministic output
+		var sortedFiles []string
+		for f := range filesWithIssues {
+			sortedFiles = append(sortedFiles, f)
+		}
+		sort.Strings(sortedFiles)
+
+		fmt.Fprintln(os.Stderr)
+		for _, rel := range sortedFiles {
+			issues := filesWithIssues[rel]
+			fmt.Fprintf(os.Stderr, "%s\n", rel)
+
+			// Print errors first, then warnings
+			for _, iss := range issues {
+				if iss.Level == lint.Error {
+					fmt.Fprintf(os.Stderr, "  ERROR  %s\n", iss.Message)
+				}
+			}
+			for _, iss := range issues {
+				if iss.Level == lint.Warning {
+					fmt.Fprintf(os.Stderr, "  WARN   %s\n", iss.Message)
+				}
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+
+	// Summary
+	if totalErrors > 0 {
+		logger.Error(fmt.Sprintf("lint complete: %d files checked, %d errors, %d warnings", len(files), totalErrors, totalWarnings))
+		logger.Sync()
+		os.Exit(1)
+	}
+
+	if totalWarnings > 0 {
+		logger.Warn(fmt.Sprintf("lint complete: %d files checked, 0 errors, %d warnings", len(files), totalWarnings))
+		return
+	}
+
+	logger.Info(fmt.Sprintf("lint passed: %d files checked", len(files)))
+}
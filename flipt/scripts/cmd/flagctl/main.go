@@ -0,0 +1,361 @@
+// Command flagctl is the one entry point for the flag tooling: linting and
+// formatting flag files, generating ACL data, managing namespaces and flags,
+// and syncing shared segments.
+//
+//	flagctl [--flags-dir dir] [--log-format console|json] [--no-color] <command> [flags] [args]
+//
+// The global flags can go before or after the command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/internal/cli"
+	"go.uber.org/zap"
+)
+
+// command is a flagctl subcommand, or a group of them when it has
+// subcommands and no setup.
+type command struct {
+	name    string
+	args    string // positional arguments, for the usage line
+	summary string
+
+	// setup registers the command's flags and returns what runs it.
+	setup func(fs *flag.FlagSet) runFunc
+
+	// argValues are the completions for the command's positional arguments.
+	argValues func(g *globals) []string
+
+	// flagValues are the completions for the values of its flags, by name.
+	flagValues map[string]func(g *globals) []string
+
+	subcommands []*command
+}
+
+type runFunc func(g *globals, args []string) error
+
+// Exit codes, so scripts can tell why a command failed. Every failure has its
+// own code across commands: 1 is anything not listed here, and 2 a usage
+// error (see exitUsage).
+const (
+	exitMissingInput       = 3  // a value --yes needs wasn't given
+	exitInvalidKey         = 4  // a namespace, flag or variant key isn't valid
+	exitNamespaceExists    = 5  // namespace new: the namespace already exists
+	exitInvalidTeam        = 6  // namespace new: a team slug isn't valid
+	exitInvalidEnvironment = 7  // an environment isn't known, or doesn't have the namespace
+	exitLintFailed         = 8  // a generated file failed lint, so nothing was written
+	exitInvalidTemplate    = 9  // namespace new: the template can't be used
+	exitInvalidType        = 10 // flag new: unknown type, or variants on a boolean flag
+	exitFlagExists         = 11 // flag new: the flag already exists
+	exitInvalidSegment     = 12 // flag new: the segment isn't defined in the namespace
+)
+
+// usageError is a mistake in how a command was invoked, reported alongside
+// the command's usage.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+var commands = []*command{
+	{
+		name:    "lint",
+		summary: "Check flag files against Flipt's schema, the access rules and canonical formatting",
+		setup:   lintCommand,
+	},
+	{
+		name:    "fmt",
+		summary: "Rewrite flag files in canonical form, keeping comments",
+		setup:   fmtCommand,
+	},
+	{
+		name:    "acl",
+		summary: "Generate the ACL data Flipt's OPA policy reads",
+		subcommands: []*command{
+			{
+				name:    "generate",
+				args:    "<output-path>",
+				summary: "Write acl-data.json (and optionally an OPA bundle) once",
+				setup:   aclGenerateCommand,
+			},
+			{
+				name:    "watch",
+				args:    "<output-path>",
+				summary: "Regenerate acl-data.json whenever the flags change or a grant expires",
+				setup:   aclWatchCommand,
+			},
+		},
+	},
+	{
+		name:    "namespace",
+		summary: "Manage namespaces",
+		subcommands: []*command{
+			{
+				name:    "new",
+				summary: "Scaffold a namespace's features.yml and access.yml in each environment",
+				setup:   namespaceNewCommand,
+				flagValues: map[string]func(g *globals) []string{
+					"env":      func(g *globals) []string { return flagfile.Environments(g.flagsDir) },
+					"template": func(g *globals) []string { return templateNames(g.flagsDir) },
+				},
+			},
+			{
+				name:    "rename",
+				args:    "<old-key> <new-key>",
+				summary: "Move a namespace to a new key in every environment",
+				setup:   namespaceRenameCommand,
+			},
+			{
+				name:    "remove",
+				args:    "<namespace-key>",
+				summary: "Decommission a namespace in every environment, archiving its flags first",
+				setup:   namespaceRemoveCommand,
+			},
+		},
+	},
+	{
+		name:    "flag",
+		summary: "Manage flags",
+		subcommands: []*command{
+			{
+				name:    "new",
+				summary: "Add a flag to a namespace in every environment it's in",
+				setup:   flagNewCommand,
+				flagValues: map[string]func(g *globals) []string{
+					"env":  func(g *globals) []string { return flagfile.Environments(g.flagsDir) },
+					"type": func(*globals) []string { return []string{"boolean", "variant"} },
+				},
+			},
+			{
+				name:    "set",
+				args:    "<env> <namespace> [<flag-key>...]",
+				summary: "Enable or disable flags in one namespace, printing a summary for the PR",
+				setup:   flagSetCommand,
+				flagValues: map[string]func(g *globals) []string{
+					"enabled": func(*globals) []string { return []string{"true", "false"} },
+				},
+			},
+		},
+	},
+	{
+		name:    "segments",
+		summary: "Inline the shared segments in flags/segments/ into the namespaces using them",
+		subcommands: []*command{
+			{
+				name:    "sync",
+				summary: "Inline referenced shared segments and report copies that have drifted",
+				setup:   segmentsSyncCommand,
+			},
+			{
+				name:    "check",
+				summary: "Report missing and drifted segments without changing any files",
+				setup:   segmentsCheckCommand,
+			},
+		},
+	},
+	{
+		name:      "completion",
+		args:      "bash|zsh|fish",
+		summary:   "Print a shell completion script",
+		setup:     completionCommand,
+		argValues: func(*globals) []string { return shells },
+	},
+}
+
+// globals are the flags every command accepts.
+type globals struct {
+	flagsDir  string
+	logFormat string
+	noColor   bool
+
+	logger *zap.Logger
+}
+
+// newGlobals returns the globals' defaults.
+func newGlobals() *globals {
+	return &globals{
+		flagsDir:  "flags",
+		logFormat: "console",
+		noColor:   os.Getenv("NO_COLOR") != "",
+	}
+}
+
+// register adds the global flags to fs, defaulting to their current values so
+// flags given before the command carry through to it.
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.flagsDir, "flags-dir", g.flagsDir, "directory of flag files")
+	fs.StringVar(&g.logFormat, "log-format", g.logFormat, "log output format: "+strings.Join(cli.LogFormats, " or "))
+	fs.BoolVar(&g.noColor, "no-color", g.noColor, "don't colour output (also set by NO_COLOR)")
+}
+
+// globalValues are the completions for the global flags' values.
+var globalValues = map[string]func(g *globals) []string{
+	"log-format": func(*globals) []string { return cli.LogFormats },
+}
+
+// newFlagSet returns cmd's flags, the globals included, and what runs it.
+func newFlagSet(g *globals, path string, cmd *command) (*flag.FlagSet, runFunc) {
+	fs := flag.NewFlagSet("flagctl "+path, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	g.register(fs)
+
+	var run runFunc
+	if cmd.setup != nil {
+		run = cmd.setup(fs)
+	}
+	return fs, run
+}
+
+// parseArgs parses flags wherever they appear among the positional arguments,
+// so `flagctl acl generate out.json --strict` works. Everything after a "--"
+// is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// resolve walks args down the command tree, returning the deepest command
+// they name, its path, and the arguments left over.
+func resolve(args []string) (*command, string, []string) {
+	cmd := &command{name: "flagctl", subcommands: commands}
+	var path []string
+
+	for len(args) > 0 {
+		i := slices.IndexFunc(cmd.subcommands, func(c *command) bool { return c.name == args[0] })
+		if i < 0 {
+			break
+		}
+
+		cmd = cmd.subcommands[i]
+		path = append(path, cmd.name)
+		args = args[1:]
+	}
+
+	return cmd, strings.Join(path, " "), args
+}
+
+// printUsage writes help for cmd: its subcommands for a group, its flags
+// otherwise.
+func printUsage(w io.Writer, path string, cmd *command) {
+	name := strings.TrimSpace("flagctl " + path)
+
+	if len(cmd.subcommands) > 0 {
+		fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", name)
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(w, "  %-12s %s\n", sub.name, sub.summary)
+		}
+		fmt.Fprintf(w, "\nRun '%s <command> --help' for a command's flags.\n", name)
+		if path != "" {
+			return
+		}
+	} else {
+		usage := name + " [flags]"
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(w, "Usage: %s\n\n%s.\n", usage, cmd.summary)
+	}
+
+	fs, _ := newFlagSet(newGlobals(), path, cmd)
+	fs.SetOutput(w)
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+func main() {
+	g := newGlobals()
+
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		for _, candidate := range complete(g, os.Args[2:]) {
+			fmt.Println(candidate)
+		}
+		return
+	}
+
+	root := flag.NewFlagSet("flagctl", flag.ContinueOnError)
+	root.SetOutput(io.Discard)
+	g.register(root)
+
+	if err := root.Parse(os.Args[1:]); err != nil {
+		exitUsage("", &command{subcommands: commands}, err)
+	}
+
+	if g.noColor {
+		cli.DisableColour()
+	}
+
+	cmd, path, args := resolve(root.Args())
+	if len(cmd.subcommands) > 0 {
+		switch {
+		case len(args) == 0:
+			printUsage(os.Stderr, path, cmd)
+			os.Exit(2)
+		case args[0] == "help":
+			cmd, path, _ = resolve(append(strings.Fields(path), args[1:]...))
+			printUsage(os.Stdout, path, cmd)
+			return
+		default:
+			exitUsage(path, cmd, usageError(fmt.Sprintf("unknown command %q", args[0])))
+		}
+	}
+
+	fs, run := newFlagSet(g, path, cmd)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitUsage(path, cmd, err)
+	}
+
+	if g.noColor {
+		cli.DisableColour()
+	}
+
+	g.logger, err = cli.NewLoggerFormat(g.logFormat)
+	if err != nil {
+		exitUsage(path, cmd, err)
+	}
+	defer g.logger.Sync()
+
+	if err := run(g, args); err != nil {
+		var usage usageError
+		if errors.As(err, &usage) {
+			exitUsage(path, cmd, err)
+		}
+		g.logger.Fatal(path+" failed", zap.Error(err))
+	}
+}
+
+// exitUsage reports err with cmd's usage and exits 2, or prints the usage and
+// exits cleanly if err is a request for help.
+func exitUsage(path string, cmd *command, err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(os.Stdout, path, cmd)
+		os.Exit(0)
+	}
+
+	cli.Fail(err.Error())
+	fmt.Fprintln(os.Stderr)
+	printUsage(os.Stderr, path, cmd)
+	os.Exit(2)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		strict     bool
+	}{
+		{"flags first", []string{"--strict", "out.json"}, []string{"out.json"}, true},
+		{"flags after", []string{"out.json", "--strict"}, []string{"out.json"}, true},
+		{"no flags", []string{"out.json"}, []string{"out.json"}, false},
+		{"after terminator", []string{"--", "--strict"}, []string{"--strict"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			strict := fs.Bool("strict", false, "")
+
+			positional, err := parseArgs(fs, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(positional, tt.positional) {
+				t.Errorf("positional: got %q, want %q", positional, tt.positional)
+			}
+			if *strict != tt.strict {
+				t.Errorf("strict: got %v, want %v", *strict, tt.strict)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		args []string
+		path string
+		rest []string
+	}{
+		{nil, "", nil},
+		{[]string{"lint", "--jobs", "2"}, "lint", []string{"--jobs", "2"}},
+		{[]string{"acl", "watch", "out.json"}, "acl watch", []string{"out.json"}},
+		{[]string{"acl", "bogus"}, "acl", []string{"bogus"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, path, rest := resolve(tt.args)
+			if path != tt.path || !slices.Equal(rest, tt.rest) {
+				t.Errorf("got %q %q, want %q %q", path, rest, tt.path, tt.rest)
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	flagsDir := "../../../../flags"
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"commands", []string{""}, []string{"lint", "fmt", "acl", "namespace", "flag", "segments", "completion"}},
+		{"command prefix", []string{"a"}, []string{"acl"}},
+		{"subcommands", []string{"acl", ""}, []string{"generate", "watch"}},
+		{"flags", []string{"lint", "--"}, []string{"--flags-dir", "--jobs", "--log-format", "--no-color"}},
+		{"flag prefix", []string{"acl", "watch", "--st"}, []string{"--stale-after", "--strict"}},
+		{"global flag value", []string{"--log-format", ""}, []string{"console", "json"}},
+		{"flag value after =", []string{"lint", "--log-format=j"}, []string{"--log-format=json"}},
+		{"environments", []string{"--flags-dir", flagsDir, "namespace", "new", "--env", "p"}, []string{"preprod", "prod"}},
+		{"environments from a later --flags-dir", []string{"namespace", "new", "--flags-dir=" + flagsDir, "--env", ""}, []string{"dev", "preprod", "prod"}},
+		{"namespace subcommands", []string{"namespace", ""}, []string{"new", "rename", "remove"}},
+		{"flag environments", []string{"--flags-dir", flagsDir, "flag", "new", "--env", "d"}, []string{"dev"}},
+		{"value after bool flag", []string{"acl", "generate", "--strict", ""}, nil},
+		{"file path", []string{"--flags-dir", ""}, nil},
+		{"shells", []string{"completion", ""}, []string{"bash", "zsh", "fish"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := complete(newGlobals(), tt.words); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/internal/cli"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/lint"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	kebabRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]$`)
+	teamRegex  = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9_-]*[a-z0-9])?$`)
+)
+
+// fliptConfig is the part of a flipt/config/*.yml file that says which flags
+// directory backs each environment.
+type fliptConfig struct {
+	Environments map[string]struct {
+		Directory string `yaml:"directory"`
+	} `yaml:"environments"`
+}
+
+// elsewhereEnvironments are the directories Flipt configs serve that are
+// created somewhere other than this repository, so aren't expected under
+// flags/: the smoke-test instance (config/test.yml) builds flags/test inside
+// its container (see tests/).
+var elsewhereEnvironments = map[string]bool{
+	"flags/test": true,
+}
+
+// discoverEnvironments lists the environments under flagsDir (each directory
+// with a flipt.yml), and warnings for where they disagree with the
+// environments the Flipt configs in configDir serve.
+func discoverEnvironments(flagsDir, configDir string) ([]string, []string) {
+	envs := flagfile.Environments(flagsDir)
+	var warnings []string
+
+	configPaths, _ := filepath.Glob(filepath.Join(configDir, "*.yml"))
+	sort.Strings(configPaths)
+
+	served := make(map[string]bool)
+	for _, configPath := range configPaths {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Couldn't read %s: %v", configPath, err))
+			continue
+		}
+
+		var config fliptConfig
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Couldn't parse %s: %v", configPath, err))
+			continue
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(config.Environments)) {
+			dir := path.Clean(config.Environments[name].Directory)
+			if path.Dir(dir) != "flags" {
+				continue
+			}
+
+			served[path.Base(dir)] = true
+			if elsewhereEnvironments[dir] {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(flagsDir, path.Base(dir))); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s serves environment '%s' from %s, which doesn't exist.", filepath.Base(configPath), name, dir))
+				continue
+			}
+			if !slices.Contains(envs, path.Base(dir)) {
+				warnings = append(warnings, fmt.Sprintf("%s serves environment '%s' from %s, which has no flipt.yml.", filepath.Base(configPath), name, dir))
+			}
+		}
+	}
+
+	for _, env := range envs {
+		if !served[env] {
+			warnings = append(warnings, fmt.Sprintf("flags/%s has a flipt.yml but no config in %s serves it.", env, configDir))
+		}
+	}
+
+	return envs, warnings
+}
+
+// selectEnvironments returns the environments in envs that were requested, in
+// envs order, or the first requested name that isn't one of them.
+func selectEnvironments(envs, requested []string) ([]string, string) {
+	for _, name := range requested {
+		if !slices.Contains(envs, strings.ToLower(name)) {
+			return nil, name
+		}
+	}
+
+	return slices.DeleteFunc(slices.Clone(envs), func(env string) bool {
+		return !slices.ContainsFunc(requested, func(name string) bool { return strings.ToLower(name) == env })
+	}), ""
+}
+
+// templateNames lists the templates in flags/templates/.
+func templateNames(flagsDir string) []string {
+	var names []string
+
+	matches, _ := filepath.Glob(filepath.Join(flagsDir, "templates", "*.yml"))
+	for _, match := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(match), ".yml"))
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// loadTemplate reads flags/templates/<name>.yml and returns its flags and
+// segments entries, ready to add to a features.yml mapping, with every
+// ${namespace} replaced by nsKey.
+func loadTemplate(flagsDir, name, nsKey string) ([]*yaml.Node, error) {
+	data, err := os.ReadFile(filepath.Join(flagsDir, "templates", name+".yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("template must be a mapping of flags and segments")
+	}
+
+	var substitute func(node *yaml.Node)
+	substitute = func(node *yaml.Node) {
+		node.Value = strings.ReplaceAll(node.Value, "${namespace}", nsKey)
+		for _, child := range node.Content {
+			substitute(child)
+		}
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; key != "flags" && key != "segments" {
+			return nil, fmt.Errorf("unexpected key %q (templates only hold flags and segments)", key)
+		}
+
+		// Comments describing the template don't belong in the namespace.
+		root.Content[i].HeadComment = ""
+		substitute(root.Content[i+1])
+	}
+
+	return root.Content, nil
+}
+
+// lintGenerated runs flagctl lint's checks over a new namespace's generated
+// files as if they were already in env, shared access included, and checks
+// every value reads back exactly as it was given.
+func lintGenerated(flagsDir, env string, features flagfile.Features, featuresData []byte, access flagfile.Access, accessData []byte) []string {
+	var problems []string
+
+	nsDir := filepath.Join(flagsDir, env, features.Namespace.Key)
+	for name, data := range map[string][]byte{"features.yml": featuresData, "access.yml": accessData} {
+		for _, iss := range lint.Content(flagsDir, filepath.Join(nsDir, name), data) {
+			if iss.Level == lint.Error {
+				problems = append(problems, fmt.Sprintf("%s: %s", name, iss.Message))
+			}
+		}
+	}
+
+	var decodedFeatures flagfile.Features
+	if yaml.Unmarshal(featuresData, &decodedFeatures) == nil && decodedFeatures.Namespace != features.Namespace {
+		problems = append(problems, "features.yml: namespace doesn't read back as entered")
+	}
+
+	var decodedAccess flagfile.Access
+	if yaml.Unmarshal(accessData, &decodedAccess) == nil {
+		if !slices.Equal(flagfile.Teams(decodedAccess.Writers), flagfile.Teams(access.Writers)) || decodedAccess.ProdSelfService != access.ProdSelfService {
+			problems = append(problems, "access.yml: access doesn't read back as entered")
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// namespaceNewCommand scaffolds a namespace's features.yml and access.yml in
+// each environment, prompting for anything not given on the command line.
+func namespaceNewCommand(fs *flag.FlagSet) runFunc {
+	var opts namespaceOptions
+	fs.StringVar(&opts.key, "key", "", "namespace key (kebab-case)")
+	fs.StringVar(&opts.name, "name", "", "display name (defaults to the key)")
+	fs.StringVar(&opts.description, "description", "", "description")
+	fs.Var(&opts.teams, "team", "GitHub team slug for write access (repeatable)")
+	fs.Var(&opts.envs, "env", "environment to create the namespace in (repeatable, defaults to all)")
+	fs.StringVar(&opts.template, "template", "", "copy segments and flags from <flags-dir>/templates/<name>.yml")
+	fs.StringVar(&opts.configDir, "config-dir", "", "directory of Flipt configs to check environments against (default <flags-dir>/../flipt/config)")
+	fs.BoolVar(&opts.prodSelfService, "prod-self-service", false, "let the writers approve their own prod flag changes")
+	fs.BoolVar(&opts.yes, "yes", false, "don't prompt: missing optional values use their defaults, and the summary isn't confirmed")
+
+	return func(g *globals, args []string) error {
+		if len(args) > 0 {
+			return usageError("unexpected arguments: " + strings.Join(args, " "))
+		}
+
+		newNamespace(g.flagsDir, opts)
+		return nil
+	}
+}
+
+// namespaceOptions are the namespace new flags.
+type namespaceOptions struct {
+	key, name, description string
+	teams, envs            cli.ListFlag
+	template               string
+	configDir              string
+	prodSelfService        bool
+	yes                    bool
+}
+
+// newNamespace runs the namespace new wizard, exiting with one of the exit
+// codes in main.go if the namespace can't be created.
+func newNamespace(flagsDir string, opts namespaceOptions) {
+	if opts.configDir == "" {
+		opts.configDir = filepath.Join(flagsDir, "..", "flipt", "config")
+	}
+
+	envs, warnings := discoverEnvironments(flagsDir, opts.configDir)
+	for _, warning := range warnings {
+		cli.Warn(warning)
+	}
+	if len(envs) == 0 {
+		cli.Exit(exitInvalidEnvironment, fmt.Sprintf("No environments found in %s (looking for */flipt.yml).", flagsDir))
+	}
+
+	// Explicit flags skip their prompts; --yes skips the rest, so a required
+	// value missing then is an error rather than a prompt nobody will answer.
+	selectedEnvs := envs
+	if len(opts.envs) > 0 {
+		var unknown string
+		if selectedEnvs, unknown = selectEnvironments(envs, opts.envs); unknown != "" {
+			cli.Exit(exitInvalidEnvironment, fmt.Sprintf("Unknown environment '%s' (expected one of: %s).", unknown, strings.Join(envs, ", ")))
+		}
+	}
+
+	if opts.yes && opts.key == "" {
+		cli.Exit(exitMissingInput, "--key is required with --yes.")
+	}
+	if opts.yes && len(opts.teams) == 0 {
+		cli.Exit(exitMissingInput, "At least one --team is required with --yes.")
+	}
+
+	fmt.Println()
+	cli.Info("============================================")
+	cli.Info("  Create a new Flipt namespace")
+	cli.Info("============================================")
+	fmt.Println()
+	cli.Info("This will scaffold a new namespace in your chosen")
+	cli.Info(fmt.Sprintf("environments (%s) with the", strings.Join(envs, ", ")))
+	cli.Info("required features.yml and access.yml files.")
+	fmt.Println()
+
+	// --- Gather inputs ---
+
+	nsKey := opts.key
+	if nsKey == "" {
+		nsKey = cli.Prompt("Namespace key (kebab-case, e.g. my-service)", "")
+	}
+	nsKey = strings.ToLower(strings.ReplaceAll(nsKey, " ", "-"))
+
+	if !kebabRegex.MatchString(nsKey) {
+		cli.Exit(exitInvalidKey, "Namespace key must be kebab-case (lowercase letters, numbers, hyphens).")
+	}
+
+	if len(opts.envs) == 0 && !opts.yes {
+		requested := strings.FieldsFunc(cli.Prompt("Environments (comma-separated)", strings.Join(envs, ", ")), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+
+		var unknown string
+		if selectedEnvs, unknown = selectEnvironments(envs, requested); unknown != "" {
+			cli.Exit(exitInvalidEnvironment, fmt.Sprintf("Unknown environment '%s' (expected one of: %s).", unknown, strings.Join(envs, ", ")))
+		}
+	}
+
+	if opts.prodSelfService && !slices.Contains(selectedEnvs, "prod") {
+		cli.Exit(exitInvalidEnvironment, "--prod-self-service needs the namespace to be created in prod.")
+	}
+
+	for _, env := range selectedEnvs {
+		if _, err := os.Stat(filepath.Join(flagsDir, env, nsKey)); err == nil {
+			cli.Exit(exitNamespaceExists, fmt.Sprintf("Namespace '%s' already exists in %s!", nsKey, env))
+		}
+	}
+
+	nsName := opts.name
+	if nsName == "" && opts.yes {
+		nsName = nsKey
+	} else if nsName == "" {
+		nsName = cli.Prompt("Display name", nsKey)
+	}
+
+	nsDesc := opts.description
+	if nsDesc == "" && !opts.yes {
+		nsDesc = cli.OptionalPrompt("Description (optional)")
+	}
+
+	template := opts.template
+	if template == "" && !opts.yes {
+		if names := templateNames(flagsDir); len(names) > 0 {
+			template = cli.OptionalPrompt(fmt.Sprintf("Template (optional: %s)", strings.Join(names, ", ")))
+		}
+	}
+	if template != "" && !slices.Contains(templateNames(flagsDir), template) {
+		cli.Exit(exitInvalidTemplate, fmt.Sprintf("Unknown template '%s' (expected one of: %s).", template, strings.Join(templateNames(flagsDir), ", ")))
+	}
+
+	ghTeams := opts.teams
+	if len(ghTeams) == 0 {
+		fmt.Println()
+		ghTeams = cli.PromptList("GitHub team slugs for write access:")
+	}
+
+	for _, team := range ghTeams {
+		if !teamRegex.MatchString(team) {
+			cli.Exit(exitInvalidTeam, fmt.Sprintf("'%s' isn't a GitHub team slug (lowercase letters, numbers, hyphens, underscores).", team))
+		}
+	}
+
+	// --- Summary ---
+
+	fmt.Println()
+	cli.Info("============================================")
+	cli.Info("  Summary")
+	cli.Info("============================================")
+	fmt.Println()
+	fmt.Printf("  %sNamespace:%s    %s\n", cli.Bold, cli.Reset, nsKey)
+	if nsName != "" {
+		fmt.Printf("  %sName:%s         %s\n", cli.Bold, cli.Reset, nsName)
+	}
+	if nsDesc != "" {
+		fmt.Printf("  %sDescription:%s  %s\n", cli.Bold, cli.Reset, nsDesc)
+	}
+	if template != "" {
+		fmt.Printf("  %sTemplate:%s     %s\n", cli.Bold, cli.Reset, template)
+	}
+	fmt.Printf("  %sTeams:%s        %s\n", cli.Bold, cli.Reset, strings.Join(ghTeams, ", "))
+	fmt.Printf("  %sEnvironments:%s %s\n", cli.Bold, cli.Reset, strings.Join(selectedEnvs, ", "))
+	if opts.prodSelfService {
+		fmt.Printf("  %sProd self-service:%s yes\n", cli.Bold, cli.Reset)
+	}
+	fmt.Println()
+
+	if !opts.yes && !cli.Confirm("Create this namespace? [Y/n]:", true) {
+		cli.Warn("Aborted.")
+		return
+	}
+
+	// --- Generate and check files ---
+
+	features := flagfile.Features{Namespace: flagfile.Namespace{Key: nsKey, Name: nsName, Description: nsDesc}}
+
+	var featuresDoc yaml.Node
+	if err := featuresDoc.Encode(features); err != nil {
+		cli.Exit(1, fmt.Sprintf("Failed to generate features.yml: %v", err))
+	}
+
+	if template != "" {
+		templateContent, err := loadTemplate(flagsDir, template, nsKey)
+		if err != nil {
+			cli.Exit(exitInvalidTemplate, fmt.Sprintf("Failed to load template '%s': %v", template, err))
+		}
+		featuresDoc.Content = append(featuresDoc.Content, templateContent...)
+	}
+
+	featuresData, err := flagfile.Encode(&featuresDoc)
+	if err != nil {
+		cli.Exit(1, fmt.Sprintf("Failed to generate features.yml: %v", err))
+	}
+
+	accessByEnv := make(map[string][]byte)
+	for _, env := range selectedEnvs {
+		access := flagfile.Access{ProdSelfService: env == "prod" && opts.prodSelfService}
+		for _, team := range ghTeams {
+			access.Writers = append(access.Writers, flagfile.AccessGrant{Team: team})
+		}
+		accessData, err := flagfile.Encode(access)
+		if err != nil {
+			cli.Exit(1, fmt.Sprintf("Failed to generate access.yml for %s: %v", env, err))
+		}
+
+		if problems := lintGenerated(flagsDir, env, features, featuresData, access, accessData); len(problems) > 0 {
+			for _, problem := range problems {
+				cli.Fail(fmt.Sprintf("  %s/%s/%s", env, nsKey, problem))
+			}
+			cli.Exit(exitLintFailed, "Generated files failed lint, nothing was written.")
+		}
+
+		accessByEnv[env] = accessData
+	}
+
+	// --- Create files ---
+
+	fmt.Println()
+	for _, env := range selectedEnvs {
+		dir := filepath.Join(flagsDir, env, nsKey)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to create directory %s: %v", dir, err))
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "features.yml"), featuresData, 0644); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to write features.yml in %s: %v", env, err))
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "access.yml"), accessByEnv[env], 0644); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to write access.yml in %s: %v", env, err))
+			os.Exit(1)
+		}
+
+		cli.OK(fmt.Sprintf("  Created %s/%s/", env, nsKey))
+	}
+
+	fmt.Println()
+	cli.OK(fmt.Sprintf("Namespace '%s' created in %s.", nsKey, strings.Join(selectedEnvs, ", ")))
+	fmt.Println()
+	cli.Info("Next steps:")
+	cli.Info("  1. Add your flags to the features.yml files")
+	cli.Info("  2. Run 'make flags-lint' to validate them")
+	cli.Info("  3. Raise a PR to main")
+	fmt.Println()
+}
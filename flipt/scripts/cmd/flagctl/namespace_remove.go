@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/acl"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/internal/cli"
+)
+
+// namespaceRemoveCommand decommissions a namespace in every environment,
+// archiving its flags to a decommission record first.
+func namespaceRemoveCommand(fs *flag.FlagSet) runFunc {
+	var opts removeOptions
+	fs.BoolVar(&opts.force, "force", false, "remove the namespace even if a read-only environment still has enabled flags")
+	fs.BoolVar(&opts.yes, "yes", false, "don't ask for confirmation")
+	fs.StringVar(&opts.recordDir, "record-dir", "", "directory for the decommission record (default <flags-dir>/decommissioned)")
+
+	return func(g *globals, args []string) error {
+		if len(args) != 1 {
+			return usageError("expected <namespace-key>")
+		}
+
+		removeNamespace(g.flagsDir, args[0], opts)
+		return nil
+	}
+}
+
+// removeOptions are the namespace remove flags.
+type removeOptions struct {
+	force     bool
+	yes       bool
+	recordDir string
+}
+
+// removeNamespace archives and deletes key, exiting 1 if it can't.
+func removeNamespace(flagsDir, key string, opts removeOptions) {
+	if opts.recordDir == "" {
+		opts.recordDir = filepath.Join(flagsDir, "decommissioned")
+	}
+
+	// --- Find the namespace ---
+
+	var nsDirs []string
+	for _, env := range flagfile.Environments(flagsDir) {
+		candidates, _ := filepath.Glob(filepath.Join(flagsDir, env, "*"))
+		for _, nsDir := range candidates {
+			if fi, err := os.Stat(nsDir); err != nil || !fi.IsDir() {
+				continue
+			}
+			if filepath.Base(nsDir) == key || namespaceKey(nsDir) == key {
+				nsDirs = append(nsDirs, nsDir)
+			}
+		}
+	}
+
+	if len(nsDirs) == 0 {
+		cli.Fail(fmt.Sprintf("Namespace '%s' doesn't exist in any environment.", key))
+		os.Exit(1)
+	}
+
+	recordPath := filepath.Join(opts.recordDir, key+".yml")
+	if _, err := os.Stat(recordPath); err == nil {
+		cli.Fail(fmt.Sprintf("Decommission record %s already exists!", recordPath))
+		os.Exit(1)
+	}
+
+	// --- Safety checks ---
+
+	// Removing a namespace makes every flag in it evaluate as missing, which a
+	// service in a read-only environment (prod, and its aliases) will notice.
+	// Enabled flags there mean something may still rely on it.
+	cfg := loadACLConfig(flagsDir)
+	fmt.Println()
+	var blocked []string
+	for _, nsDir := range nsDirs {
+		env := filepath.Base(filepath.Dir(nsDir))
+
+		enabled, err := flagfile.EnabledFlags(nsDir)
+		if err != nil {
+			cli.Fail(fmt.Sprintf("Couldn't read flags in %s: %v", nsDir, err))
+			os.Exit(1)
+		}
+
+		switch {
+		case len(enabled) == 0:
+			cli.OK(fmt.Sprintf("  %s: no enabled flags", env))
+		case cfg.ReadOnly(env) && !opts.force:
+			cli.Fail(fmt.Sprintf("  %s: %d enabled flags (%s)", env, len(enabled), strings.Join(enabled, ", ")))
+			blocked = append(blocked, env)
+		default:
+			cli.Warn(fmt.Sprintf("  %s: %d enabled flags (%s)", env, len(enabled), strings.Join(enabled, ", ")))
+		}
+	}
+
+	if len(blocked) > 0 {
+		fmt.Println()
+		cli.Fail(fmt.Sprintf("Enabled flags remain in read-only %s. Disable them first, or pass --force if nothing evaluates them.", strings.Join(blocked, ", ")))
+		os.Exit(1)
+	}
+
+	// --- Preview the ACL change ---
+
+	data, aliases := collectACL(flagsDir, cfg)
+	before := data.Entries()
+	for _, nsDir := range nsDirs {
+		env := acl.CanonicalEnvironment(aliases, filepath.Base(filepath.Dir(nsDir)))
+		data.RemoveNamespace(env, namespaceKey(nsDir))
+	}
+	after := data.Entries()
+
+	var removed []string
+	for _, entry := range before {
+		if !slices.Contains(after, entry) {
+			removed = append(removed, entry)
+		}
+	}
+
+	fmt.Println()
+	cli.Info(fmt.Sprintf("Removing '%s' from:", key))
+	for _, nsDir := range nsDirs {
+		fmt.Printf("  %s\n", nsDir)
+	}
+	fmt.Println()
+	cli.Info("acl-data.json entries that will disappear:")
+	for _, entry := range removed {
+		fmt.Printf("%s- %s%s\n", cli.Red, entry, cli.Reset)
+	}
+	if len(removed) == 0 {
+		fmt.Println("  (none)")
+	}
+	fmt.Println()
+
+	if !opts.yes && !cli.Confirm(fmt.Sprintf("Permanently remove '%s'? [y/N]:", key), false) {
+		cli.Warn("Aborted.")
+		return
+	}
+
+	// --- Archive and delete ---
+
+	record := flagfile.DecommissionRecord{
+		Namespace:      key,
+		Decommissioned: time.Now().UTC().Truncate(time.Second),
+		Forced:         opts.force,
+		RemovedACL:     removed,
+	}
+	if err := flagfile.WriteDecommissionRecord(recordPath, record, nsDirs); err != nil {
+		cli.Fail(fmt.Sprintf("Failed to write decommission record: %v", err))
+		os.Exit(1)
+	}
+	cli.OK(fmt.Sprintf("  Archived to %s", recordPath))
+
+	for _, nsDir := range nsDirs {
+		if err := os.RemoveAll(nsDir); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to remove %s: %v", nsDir, err))
+			os.Exit(1)
+		}
+		cli.OK(fmt.Sprintf("  Removed %s", nsDir))
+	}
+
+	sharedPath := flagfile.SharedAccessPath(flagsDir, nsDirs[0])
+	if _, err := os.Stat(sharedPath); err == nil {
+		if err := os.Remove(sharedPath); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to remove %s: %v", sharedPath, err))
+			os.Exit(1)
+		}
+		cli.OK(fmt.Sprintf("  Removed %s", sharedPath))
+	}
+
+	fmt.Println()
+	cli.OK(fmt.Sprintf("Namespace '%s' decommissioned.", key))
+	fmt.Println()
+	cli.Info("Next steps:")
+	cli.Info("  1. Remove the namespace from any services still configured with it")
+	cli.Info("  2. Raise a PR to main, including the decommission record")
+	fmt.Println()
+}
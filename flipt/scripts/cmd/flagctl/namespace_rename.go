@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/acl"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/internal/cli"
+	"go.uber.org/zap"
+)
+
+// loadACLConfig reads acl-config.yml from flagsDir, warning and falling back
+// to the defaults if it can't.
+func loadACLConfig(flagsDir string) acl.Config {
+	cfg, err := acl.LoadConfig(flagsDir)
+	if err != nil {
+		cli.Warn(fmt.Sprintf("Couldn't read acl-config.yml, using the defaults: %v", err))
+		return acl.DefaultConfig
+	}
+	return cfg
+}
+
+// collectACL builds the access flagctl acl generate would write from flagsDir
+// under cfg, returning it with the environment aliases it was canonicalised
+// through.
+func collectACL(flagsDir string, cfg acl.Config) (acl.Data, map[string]string) {
+	aliases := cfg.Aliases()
+	data, _, _ := acl.Collect(context.Background(), zap.NewNop(), flagsDir, aliases, time.Now())
+	return data, aliases
+}
+
+// namespaceKey returns the namespace.key a namespace directory declares, or
+// the directory name if none of its files declare one.
+func namespaceKey(nsDir string) string {
+	if key := flagfile.NamespaceKey(nsDir); key != "" {
+		return key
+	}
+	return filepath.Base(nsDir)
+}
+
+// rewriteNamespaceKey sets namespace.key in the features file at path.
+func rewriteNamespaceKey(path, newKey string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := flagfile.SetNamespaceKey(data, newKey)
+	if err != nil || bytes.Equal(out, data) {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// namespaceRenameCommand moves a namespace to a new key in every
+// environment, previewing the acl-data.json entries the rename changes.
+func namespaceRenameCommand(fs *flag.FlagSet) runFunc {
+	var opts renameOptions
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "only print the acl-data.json changes the rename would cause")
+	fs.BoolVar(&opts.yes, "yes", false, "don't ask for confirmation")
+
+	return func(g *globals, args []string) error {
+		if len(args) != 2 {
+			return usageError("expected <old-key> <new-key>")
+		}
+
+		renameNamespace(g.flagsDir, args[0], args[1], opts)
+		return nil
+	}
+}
+
+// renameOptions are the namespace rename flags.
+type renameOptions struct {
+	dryRun bool
+	yes    bool
+}
+
+// renameNamespace moves oldKey to newKey, exiting 1 if it can't.
+func renameNamespace(flagsDir, oldKey, newKey string, opts renameOptions) {
+	if !kebabRegex.MatchString(newKey) {
+		cli.Fail("New namespace key must be kebab-case (lowercase letters, numbers, hyphens).")
+		os.Exit(1)
+	}
+
+	// --- Find the namespace and check the new key is free ---
+
+	var oldDirs []string
+	for _, env := range flagfile.Environments(flagsDir) {
+		envDir := filepath.Join(flagsDir, env)
+
+		nsDirs, _ := filepath.Glob(filepath.Join(envDir, "*"))
+		for _, nsDir := range nsDirs {
+			if fi, err := os.Stat(nsDir); err != nil || !fi.IsDir() {
+				continue
+			}
+
+			key := namespaceKey(nsDir)
+			if filepath.Base(nsDir) == newKey || key == newKey {
+				cli.Fail(fmt.Sprintf("Namespace '%s' already exists in %s!", newKey, filepath.Base(envDir)))
+				os.Exit(1)
+			}
+			if filepath.Base(nsDir) == oldKey || key == oldKey {
+				oldDirs = append(oldDirs, nsDir)
+			}
+		}
+	}
+
+	if len(oldDirs) == 0 {
+		cli.Fail(fmt.Sprintf("Namespace '%s' doesn't exist in any environment.", oldKey))
+		os.Exit(1)
+	}
+
+	oldShared := flagfile.SharedAccessPath(flagsDir, oldDirs[0])
+	newShared := filepath.Join(flagsDir, "access", newKey+".yml")
+	if _, err := os.Stat(newShared); err == nil {
+		cli.Fail(fmt.Sprintf("Shared access file %s already exists!", newShared))
+		os.Exit(1)
+	}
+
+	// --- Preview the ACL change ---
+
+	data, aliases := collectACL(flagsDir, loadACLConfig(flagsDir))
+	before := data.Entries()
+	for _, nsDir := range oldDirs {
+		env := acl.CanonicalEnvironment(aliases, filepath.Base(filepath.Dir(nsDir)))
+		data.RenameNamespace(env, namespaceKey(nsDir), newKey)
+	}
+	after := data.Entries()
+
+	fmt.Println()
+	cli.Info(fmt.Sprintf("Renaming '%s' to '%s' in:", oldKey, newKey))
+	for _, nsDir := range oldDirs {
+		fmt.Printf("  %s\n", nsDir)
+	}
+	fmt.Println()
+	cli.Info("acl-data.json changes:")
+	for _, entry := range before {
+		if !slices.Contains(after, entry) {
+			fmt.Printf("%s- %s%s\n", cli.Red, entry, cli.Reset)
+		}
+	}
+	for _, entry := range after {
+		if !slices.Contains(before, entry) {
+			fmt.Printf("%s+ %s%s\n", cli.Green, entry, cli.Reset)
+		}
+	}
+	fmt.Println()
+
+	if opts.dryRun {
+		return
+	}
+
+	if !opts.yes && !cli.Confirm("Rename this namespace? [Y/n]:", true) {
+		cli.Warn("Aborted.")
+		return
+	}
+
+	// --- Move directories and rewrite keys ---
+
+	for _, oldDir := range oldDirs {
+		newDir := filepath.Join(filepath.Dir(oldDir), newKey)
+
+		if err := os.Rename(oldDir, newDir); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to move %s: %v", oldDir, err))
+			os.Exit(1)
+		}
+
+		for _, path := range flagfile.FeaturesFiles(newDir) {
+			if err := rewriteNamespaceKey(path, newKey); err != nil {
+				cli.Fail(fmt.Sprintf("Failed to rewrite namespace.key in %s: %v", path, err))
+				os.Exit(1)
+			}
+		}
+
+		cli.OK(fmt.Sprintf("  Moved %s to %s", oldDir, newDir))
+	}
+
+	if _, err := os.Stat(oldShared); err == nil {
+		if err := os.Rename(oldShared, newShared); err != nil {
+			cli.Fail(fmt.Sprintf("Failed to move %s: %v", oldShared, err))
+			os.Exit(1)
+		}
+		cli.OK(fmt.Sprintf("  Moved %s to %s", oldShared, newShared))
+	}
+
+	fmt.Println()
+	cli.OK(fmt.Sprintf("Namespace '%s' renamed to '%s'.", oldKey, newKey))
+	fmt.Println()
+	cli.Info("Next steps:")
+	cli.Info("  1. Update any services evaluating flags in the old namespace")
+	cli.Info("  2. Run 'make flags-lint' to validate")
+	cli.Info("  3. Raise a PR to main")
+	fmt.Println()
+}
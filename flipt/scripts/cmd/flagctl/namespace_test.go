@@ -0,0 +1,205 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"gopkg.in/yaml.v3"
+)
+
+// writeTree writes files (relative path → content) under a temporary
+// directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDiscoverEnvironments(t *testing.T) {
+	config := func(dir string) string {
+		return "environments:\n  default:\n    storage: local\n    directory: " + dir + "\n"
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		envs     []string
+		warnings []string
+	}{
+		{
+			name: "configs and flags agree",
+			files: map[string]string{
+				"config/dev.yml":       config("flags/dev"),
+				"config/prod.yml":      config("./flags/prod"),
+				"flags/dev/flipt.yml":  "",
+				"flags/prod/flipt.yml": "",
+			},
+			envs: []string{"dev", "prod"},
+		},
+		{
+			name: "environment no config serves",
+			files: map[string]string{
+				"config/dev.yml":          config("flags/dev"),
+				"flags/dev/flipt.yml":     "",
+				"flags/staging/flipt.yml": "",
+			},
+			envs:     []string{"dev", "staging"},
+			warnings: []string{"flags/staging has a flipt.yml but no config in config serves it."},
+		},
+		{
+			name: "served directory without a flipt.yml",
+			files: map[string]string{
+				"config/dev.yml":       config("flags/dev"),
+				"flags/dev/flipt.yml":  "",
+				"flags/prod/.gitkeep":  "",
+				"config/prod.yml":      config("flags/prod"),
+				"config/elsewhere.yml": config("/srv/flags"),
+			},
+			envs:     []string{"dev"},
+			warnings: []string{"prod.yml serves environment 'default' from flags/prod, which has no flipt.yml."},
+		},
+		{
+			name: "served directory that doesn't exist",
+			files: map[string]string{
+				"config/dev.yml":      config("flags/dev"),
+				"config/staging.yml":  config("flags/staging"),
+				"flags/dev/flipt.yml": "",
+			},
+			envs:     []string{"dev"},
+			warnings: []string{"staging.yml serves environment 'default' from flags/staging, which doesn't exist."},
+		},
+		{
+			name: "smoke-test directory built elsewhere",
+			files: map[string]string{
+				"config/dev.yml":      config("flags/dev"),
+				"config/test.yml":     config("flags/test"),
+				"flags/dev/flipt.yml": "",
+			},
+			envs: []string{"dev"},
+		},
+		{
+			name: "unparseable config",
+			files: map[string]string{
+				"config/dev.yml":      "environments: [\n",
+				"flags/dev/flipt.yml": "",
+			},
+			envs:     []string{"dev"},
+			warnings: []string{"Couldn't parse config/dev.yml", "flags/dev has a flipt.yml but no config in config serves it."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+
+			envs, warnings := discoverEnvironments(filepath.Join(root, "flags"), filepath.Join(root, "config"))
+			if !slices.Equal(envs, tt.envs) {
+				t.Errorf("envs: got %q, want %q", envs, tt.envs)
+			}
+
+			// Warnings name paths under the temporary root; compare from it,
+			// and only the first line of parse errors.
+			var got []string
+			for _, w := range warnings {
+				w = strings.ReplaceAll(w, root+string(filepath.Separator), "")
+				if before, _, ok := strings.Cut(w, ": "); ok && strings.HasPrefix(w, "Couldn't") {
+					w = before
+				}
+				got = append(got, w)
+			}
+			if !slices.Equal(got, tt.warnings) {
+				t.Errorf("warnings:\n got: %q\nwant: %q", got, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		flags    []string // keys of the flags it adds
+		segments []string
+		err      bool
+	}{
+		{
+			name: "namespace substituted",
+			template: `# Describes the template, not the namespace
+flags:
+    - key: ${namespace}-enabled
+      name: ${namespace} enabled
+      type: BOOLEAN_FLAG_TYPE
+segments:
+    - key: ${namespace}-testers
+      name: Testers
+      match_type: ALL_MATCH_TYPE
+`,
+			flags:    []string{"my-service-enabled"},
+			segments: []string{"my-service-testers"},
+		},
+		{
+			name:     "other keys rejected",
+			template: "namespace:\n    key: x\n",
+			err:      true,
+		},
+		{
+			name:     "not a mapping",
+			template: "- a\n",
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagsDir := writeTree(t, map[string]string{"templates/basic.yml": tt.template})
+
+			content, err := loadTemplate(flagsDir, "basic", "my-service")
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i+1 < len(content); i += 2 {
+				if content[i].HeadComment != "" {
+					t.Errorf("template comment kept: %q", content[i].HeadComment)
+				}
+			}
+
+			var file flagfile.Features
+			root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: content}
+			if err := root.Decode(&file); err != nil {
+				t.Fatal(err)
+			}
+
+			var flags, segments []string
+			for _, f := range file.Flags {
+				flags = append(flags, f.Key)
+			}
+			for _, s := range file.Segments {
+				segments = append(segments, s.Key)
+			}
+			if !slices.Equal(flags, tt.flags) || !slices.Equal(segments, tt.segments) {
+				t.Errorf("got flags %q segments %q, want %q %q", flags, segments, tt.flags, tt.segments)
+			}
+		})
+	}
+}
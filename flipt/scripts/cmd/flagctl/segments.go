@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"go.uber.org/zap"
+)
+
+// ---------------------------------------------------------------------------
+// segments sync / check — file discovery, syncing, reporting
+// ---------------------------------------------------------------------------
+
+// segmentsSyncCommand inlines the shared segments in flags/segments/ into the
+// namespaces that reference them, and reports copies that have drifted.
+func segmentsSyncCommand(fs *flag.FlagSet) runFunc {
+	overwrite := fs.Bool("overwrite", false, "replace drifted copies with the shared definition")
+
+	return func(g *globals, args []string) error {
+		if len(args) > 0 {
+			return usageError("unexpected arguments: " + strings.Join(args, " "))
+		}
+
+		syncSegments(g.logger, g.flagsDir, false, *overwrite)
+		return nil
+	}
+}
+
+// segmentsCheckCommand reports missing and drifted segments without changing
+// any files.
+func segmentsCheckCommand(fs *flag.FlagSet) runFunc {
+	return func(g *globals, args []string) error {
+		if len(args) > 0 {
+			return usageError("unexpected arguments: " + strings.Join(args, " "))
+		}
+
+		syncSegments(g.logger, g.flagsDir, true, false)
+		return nil
+	}
+}
+
+// syncSegments syncs (or with check, only checks) every features file under
+// flagsDir, exiting 1 if a file failed or check found something to fix.
+func syncSegments(logger *zap.Logger, flagsDir string, check, overwrite bool) {
+	// Files that can't be read, parsed or written are counted rather than
+	// fatal, so one bad file doesn't hide the rest of the report, but they
+	// still fail the run: a skipped file may be missing segments.
+	library, skipped := flagfile.LoadSegmentLibrary(flagsDir)
+	for _, err := range skipped {
+		logger.Error("skipping shared segment", zap.Error(err))
+	}
+	failures := len(skipped)
+	if len(library) == 0 {
+		if failures > 0 {
+			logger.Fatal("no valid shared segments found", zap.String("path", filepath.Join(flagsDir, "segments")), zap.Int("failures", failures))
+		}
+		logger.Info("no shared segments found", zap.String("path", filepath.Join(flagsDir, "segments")))
+		return
+	}
+
+	var files []string
+	for _, pattern := range []string{"features.yml", "features.yaml"} {
+		matches, _ := filepath.Glob(filepath.Join(flagsDir, "*", "*", pattern))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	inlined, drifted := 0, 0
+	for _, path := range files {
+		rel, _ := filepath.Rel(flagsDir, path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Error("failed to read file", zap.String("path", rel), zap.Error(err))
+			failures++
+			continue
+		}
+
+		out, result, err := flagfile.SyncSegments(data, library, overwrite && !check)
+		if err != nil {
+			logger.Error("failed to sync file", zap.String("path", rel), zap.Error(err))
+			failures++
+			continue
+		}
+
+		for _, key := range result.Drifted {
+			source, _ := filepath.Rel(flagsDir, library[key].Path)
+			if overwrite && !check {
+				logger.Info("replaced drifted segment with the shared definition", zap.String("path", rel), zap.String("segment", key), zap.String("source", source))
+			} else {
+				logger.Warn("inlined segment has drifted from its source", zap.String("path", rel), zap.String("segment", key), zap.String("source", source))
+			}
+		}
+		for _, key := range result.Inlined {
+			if check {
+				logger.Warn("referenced shared segment isn't inlined", zap.String("path", rel), zap.String("segment", key))
+			} else {
+				logger.Info("inlined shared segment", zap.String("path", rel), zap.String("segment", key))
+			}
+		}
+
+		inlined += len(result.Inlined)
+		drifted += len(result.Drifted) - len(result.Overwritten)
+
+		if check || bytes.Equal(out, data) {
+			continue
+		}
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			logger.Error("failed to write file", zap.String("path", rel), zap.Error(err))
+			failures++
+		}
+	}
+
+	summary := fmt.Sprintf("sync complete: %d shared segments, %d files checked, %d inlined, %d drifted, %d failed", len(library), len(files), inlined, drifted, failures)
+	switch {
+	case failures > 0, check && (inlined > 0 || drifted > 0):
+		logger.Error(summary)
+		os.Exit(1)
+	case drifted > 0:
+		logger.Warn(summary)
+	default:
+		logger.Info(summary)
+	}
+}
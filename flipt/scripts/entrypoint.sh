@@ -4,6 +4,9 @@ set -eu
 REPO_PATH="${FLIPT_GIT_REPO_PATH:-/var/opt/flipt/repo}"
 ACL_DATA_PATH="${FLIPT_AUTHORIZATION_LOCAL_DATA_PATH:-/var/opt/flipt/acl-data.json}"
 CONFIG_FILE="${FLIPT_CONFIG_FILE:-/etc/flipt/config/default.yml}"
+ACL_LISTEN_ADDRESS="${ACL_LISTEN_ADDRESS:-}"
+ACL_AUDIT_LOG_PATH="${ACL_AUDIT_LOG_PATH:-}"
+ACL_BRANCH_REF_PREFIX="${ACL_BRANCH_REF_PREFIX:-}"
 
 # .env files can't hold multi-line values, so local runs supply the GitHub App
 # private key base64-encoded and it's decoded here.
@@ -12,8 +15,38 @@ if [ -z "${FLIPT_GITHUB_APP_PRIVATE_KEY:-}" ] && [ -n "${FLIPT_GITHUB_APP_PRIVAT
   export FLIPT_GITHUB_APP_PRIVATE_KEY
 fi
 
-# Keep ACL data in sync as Flipt pulls repo updates
-generate-acl-data --watch "${REPO_PATH}/flags" "$ACL_DATA_PATH" &
+# Keep ACL data in sync as Flipt pulls repo updates. Strict mode keeps the last
+# known-good data if an access.yml breaks, rather than locking the team out.
+# Set ACL_LISTEN_ADDRESS (e.g. ":9102") to serve its /healthz and /metrics, and
+# ACL_AUDIT_LOG_PATH to also append every grant/revoke to a JSONL file.
+# ACL_BRANCH_REF_PREFIX (e.g. "refs/remotes/origin/flipt/") reads branch
+# environments' ACLs from the git refs under it.
+flagctl --flags-dir "${REPO_PATH}/flags" acl watch --strict \
+  ${ACL_LISTEN_ADDRESS:+--listen "$ACL_LISTEN_ADDRESS"} \
+  ${ACL_AUDIT_LOG_PATH:+--audit-log "$ACL_AUDIT_LOG_PATH"} \
+  ${ACL_BRANCH_REF_PREFIX:+--branch-ref-prefix "$ACL_BRANCH_REF_PREFIX"} \
+  "$ACL_DATA_PATH" &
+ACL_PID=$!
 
 # Start Flipt
-exec /flipt server --config "$CONFIG_FILE" "$@"
+/flipt server --config "$CONFIG_FILE" "$@" &
+FLIPT_PID=$!
+
+# This shell stays PID 1 so it can forward signals: SIGHUP makes the ACL
+# watcher regenerate now, SIGTERM/SIGINT stop both processes, letting the
+# watcher finish any in-progress write before it exits.
+trap 'kill -HUP "$ACL_PID" 2>/dev/null || true' HUP
+trap 'kill -TERM "$FLIPT_PID" "$ACL_PID" 2>/dev/null || true' TERM INT
+
+# wait returns early (with 128+signal) whenever a trapped signal arrives, so
+# keep waiting until Flipt has actually exited, keeping only the status of the
+# last wait.
+status=0
+while kill -0 "$FLIPT_PID" 2>/dev/null; do
+  if wait "$FLIPT_PID"; then status=0; else status=$?; fi
+done
+
+kill -TERM "$ACL_PID" 2>/dev/null || true
+wait "$ACL_PID" || true
+
+exit "$status"
@@ -0,0 +1,78 @@
+package flagfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DecommissionRecord is the archive written for a removed namespace.
+type DecommissionRecord struct {
+	Namespace      string                          `yaml:"namespace"`
+	Decommissioned time.Time                       `yaml:"decommissioned"`
+	Forced         bool                            `yaml:"forced,omitempty"`
+	RemovedACL     []string                        `yaml:"removedACL,omitempty"`
+	Environments   map[string]map[string]yaml.Node `yaml:"environments"`
+}
+
+// EnabledFlags lists the keys of the enabled flags in a namespace directory,
+// sorted.
+func EnabledFlags(nsDir string) ([]string, error) {
+	var keys []string
+	for _, path := range FeaturesFiles(nsDir) {
+		file, err := LoadFeatures(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range file.Flags {
+			if f.Enabled {
+				keys = append(keys, f.Key)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// WriteDecommissionRecord archives every features file of the namespace in
+// nsDirs, keyed by environment and file name, to path.
+func WriteDecommissionRecord(path string, record DecommissionRecord, nsDirs []string) error {
+	record.Environments = make(map[string]map[string]yaml.Node)
+
+	for _, nsDir := range nsDirs {
+		env := filepath.Base(filepath.Dir(nsDir))
+		record.Environments[env] = make(map[string]yaml.Node)
+
+		for _, featuresPath := range FeaturesFiles(nsDir) {
+			data, err := os.ReadFile(featuresPath)
+			if err != nil {
+				return err
+			}
+
+			var doc yaml.Node
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				return fmt.Errorf("%s: %w", featuresPath, err)
+			}
+			if len(doc.Content) > 0 {
+				record.Environments[env][filepath.Base(featuresPath)] = *doc.Content[0]
+			}
+		}
+	}
+
+	out, err := Encode(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,95 @@
+package flagfile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEnabledFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		err   bool
+	}{
+		{
+			name: "enabled across files, sorted",
+			files: map[string]string{
+				"features.yml":      "flags:\n    - {key: b, enabled: true}\n    - {key: c}\n",
+				"more.features.yml": "flags:\n    - {key: a, enabled: true}\n",
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:  "none enabled",
+			files: map[string]string{"features.yml": "flags:\n    - {key: a, enabled: false}\n"},
+		},
+		{
+			name:  "unparseable file",
+			files: map[string]string{"features.yml": "flags: [\n"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsDir := writeTree(t, tt.files)
+
+			got, err := EnabledFlags(nsDir)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %t", err, tt.err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDecommissionRecord(t *testing.T) {
+	flagsDir := writeTree(t, map[string]string{
+		"dev/my-service/features.yml":  "namespace:\n    key: my-service\n# dev only\nflags:\n    - key: f\n      enabled: true\n",
+		"prod/my-service/features.yml": "namespace:\n    key: my-service\n",
+	})
+	nsDirs := []string{filepath.Join(flagsDir, "dev", "my-service"), filepath.Join(flagsDir, "prod", "my-service")}
+	recordPath := filepath.Join(flagsDir, "decommissioned", "my-service.yml")
+
+	record := DecommissionRecord{
+		Namespace:      "my-service",
+		Decommissioned: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		RemovedACL:     []string{"namespace_team_access.dev.my-service: [\"team-a\"]"},
+	}
+	if err := WriteDecommissionRecord(recordPath, record, nsDirs); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(recordPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `namespace: my-service
+decommissioned: 2030-01-01T00:00:00Z
+removedACL:
+    - 'namespace_team_access.dev.my-service: ["team-a"]'
+environments:
+    dev:
+        features.yml:
+            namespace:
+                key: my-service
+            # dev only
+            flags:
+                - key: f
+                  enabled: true
+    prod:
+        features.yml:
+            namespace:
+                key: my-service
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package flagfile
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------------------------------------------------------
+// In-place edits to features files, keeping the rest of the document
+// ---------------------------------------------------------------------------
+
+// EnabledChange is one flag's enabled state before and after SetEnabled.
+type EnabledChange struct {
+	Key    string
+	Before bool
+	After  bool
+}
+
+// SetEnabled sets enabled on every flag in keys that the features file in
+// data defines, leaving the rest of the document (order, comments and blank
+// lines included) as it was, so the diff is only enabled lines. It returns
+// the re-encoded file and the flags it found, or data unchanged if it defines
+// none of them.
+func SetEnabled(data []byte, keys []string, enabled bool) ([]byte, []EnabledChange, error) {
+	var changes []EnabledChange
+
+	out, err := Edit(data, FeaturesOrder, func(doc *yaml.Node) (bool, error) {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			return false, nil
+		}
+
+		root := doc.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != "flags" || root.Content[i+1].Kind != yaml.SequenceNode {
+				continue
+			}
+
+			for _, f := range root.Content[i+1].Content {
+				if change, ok := setFlagEnabled(f, keys, enabled); ok {
+					changes = append(changes, change)
+				}
+			}
+		}
+
+		return len(changes) > 0, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, changes, nil
+}
+
+// setFlagEnabled sets enabled on the flag mapping f if its key is in keys.
+func setFlagEnabled(f *yaml.Node, keys []string, enabled bool) (EnabledChange, bool) {
+	if f.Kind != yaml.MappingNode {
+		return EnabledChange{}, false
+	}
+
+	var key string
+	var value *yaml.Node
+	for j := 0; j+1 < len(f.Content); j += 2 {
+		switch f.Content[j].Value {
+		case "key":
+			key = f.Content[j+1].Value
+		case "enabled":
+			value = f.Content[j+1]
+		}
+	}
+	if !slices.Contains(keys, key) {
+		return EnabledChange{}, false
+	}
+
+	change := EnabledChange{Key: key, After: enabled}
+	if value != nil {
+		change.Before, _ = strconv.ParseBool(value.Value)
+	} else {
+		// Flipt treats a missing enabled as false; it goes after the fields
+		// that precede it in canonical files.
+		value = &yaml.Node{}
+		at := len(f.Content)
+		for j := 0; j+1 < len(f.Content); j += 2 {
+			if k := f.Content[j].Value; k == "key" || k == "name" || k == "type" || k == "description" {
+				at = j + 2
+			}
+		}
+		f.Content = slices.Insert(f.Content, at, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "enabled"}, value)
+	}
+
+	value.Kind, value.Tag, value.Style = yaml.ScalarNode, "!!bool", 0
+	value.Value = strconv.FormatBool(enabled)
+	return change, true
+}
+
+// AppendFlag adds f to the flags list of the features file in data, keeping
+// the rest of the document (comments and blank lines included) as it was, and
+// re-encodes it in canonical form.
+func AppendFlag(data []byte, f Flag) ([]byte, error) {
+	var flagNode yaml.Node
+	if err := flagNode.Encode(f); err != nil {
+		return nil, err
+	}
+
+	return Edit(data, FeaturesOrder, func(doc *yaml.Node) (bool, error) {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			return false, errors.New("not a features file")
+		}
+
+		root := doc.Content[0]
+		var flags *yaml.Node
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "flags" {
+				flags = root.Content[i+1]
+			}
+		}
+
+		if flags == nil || flags.Kind != yaml.SequenceNode {
+			// Flags go after the namespace and before any segments.
+			flags = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "flags"}
+
+			at := len(root.Content)
+			for i := 0; i+1 < len(root.Content); i += 2 {
+				if root.Content[i].Value == "segments" {
+					at = i
+				}
+			}
+			root.Content = slices.Insert(root.Content, at, key, flags)
+		}
+
+		flags.Content = append(flags.Content, &flagNode)
+		return true, nil
+	})
+}
+
+// SetNamespaceKey sets namespace.key in the features file in data, leaving
+// the rest of the document (comments and blank lines included) as it was. It
+// returns data unchanged if the file has no namespace.key.
+func SetNamespaceKey(data []byte, key string) ([]byte, error) {
+	return Edit(data, FeaturesOrder, func(doc *yaml.Node) (bool, error) {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			return false, nil
+		}
+
+		changed := false
+		root := doc.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != "namespace" || root.Content[i+1].Kind != yaml.MappingNode {
+				continue
+			}
+
+			namespace := root.Content[i+1]
+			for j := 0; j+1 < len(namespace.Content); j += 2 {
+				if namespace.Content[j].Value == "key" {
+					namespace.Content[j+1].Value = key
+					namespace.Content[j+1].Style = 0
+					changed = true
+				}
+			}
+		}
+
+		return changed, nil
+	})
+}
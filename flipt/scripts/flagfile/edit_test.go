@@ -0,0 +1,350 @@
+package flagfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSetEnabled(t *testing.T) {
+	const in = `namespace:
+    key: a
+    name: A
+flags:
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+      enabled: false # off until launch
+    - key: g
+      name: G
+      type: BOOLEAN_FLAG_TYPE
+      description: no enabled field
+    - key: h
+      name: H
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true
+`
+
+	tests := []struct {
+		name    string
+		keys    []string
+		enabled bool
+		want    string
+		changes []EnabledChange
+	}{
+		{
+			name:    "existing field keeps its comment",
+			keys:    []string{"f"},
+			enabled: true,
+			want: `namespace:
+    key: a
+    name: A
+flags:
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true # off until launch
+    - key: g
+      name: G
+      type: BOOLEAN_FLAG_TYPE
+      description: no enabled field
+    - key: h
+      name: H
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true
+`,
+			changes: []EnabledChange{{Key: "f", Before: false, After: true}},
+		},
+		{
+			name:    "missing field added after description",
+			keys:    []string{"g", "h"},
+			enabled: true,
+			want: `namespace:
+    key: a
+    name: A
+flags:
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+      enabled: false # off until launch
+    - key: g
+      name: G
+      type: BOOLEAN_FLAG_TYPE
+      description: no enabled field
+      enabled: true
+    - key: h
+      name: H
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true
+`,
+			changes: []EnabledChange{{Key: "g", Before: false, After: true}, {Key: "h", Before: true, After: true}},
+		},
+		{
+			name: "unknown key leaves the file alone",
+			keys: []string{"missing"},
+			want: in,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, changes, err := SetEnabled([]byte(in), tt.keys, tt.enabled)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out, tt.want)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes: got %+v, want %+v", changes, tt.changes)
+			}
+		})
+	}
+}
+
+// withoutEnabled drops the enabled lines from a file, leaving what an edit
+// that only toggles flags must not touch.
+func withoutEnabled(data []byte) string {
+	var kept []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "enabled: ") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// countEnabled counts the flags a file sets enabled to value.
+func countEnabled(data []byte, value bool) int {
+	n := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), fmt.Sprintf("enabled: %t", value)) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSetEnabledKeepsLayout(t *testing.T) {
+	for _, path := range []string{"testdata/roundtrip/foot-comments.golden", "testdata/roundtrip/flag-comments.golden", "testdata/roundtrip/every-position.golden"} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			in, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var file Features
+			if err := yaml.Unmarshal(in, &file); err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, f := range file.Flags {
+				keys = append(keys, f.Key)
+			}
+
+			for _, enabled := range []bool{true, false} {
+				out, _, err := SetEnabled(in, keys, enabled)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Only enabled lines may be added or changed, so the change
+				// is one a toggler can approve.
+				if got, want := withoutEnabled(out), withoutEnabled(in); got != want {
+					t.Errorf("enabled=%t changed more than enabled lines:\n%s", enabled, out)
+				}
+				if got := countEnabled(out, enabled); got != len(keys) {
+					t.Errorf("enabled=%t: got %d enabled lines, want %d:\n%s", enabled, got, len(keys), out)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendFlag(t *testing.T) {
+	flag := Flag{Key: "new", Name: "New", Type: "BOOLEAN_FLAG_TYPE", Enabled: true}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  bool
+	}{
+		{
+			name: "after existing flags",
+			in: `namespace:
+    key: a
+    name: A
+flags:
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+`,
+			want: `namespace:
+    key: a
+    name: A
+flags:
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+    - key: new
+      name: New
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true
+`,
+		},
+		{
+			name: "first flag goes before segments",
+			in: `namespace:
+    key: a
+    name: A
+segments:
+    - key: s
+      name: S
+      match_type: ALL_MATCH_TYPE
+`,
+			want: `namespace:
+    key: a
+    name: A
+flags:
+    - key: new
+      name: New
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true
+segments:
+    - key: s
+      name: S
+      match_type: ALL_MATCH_TYPE
+`,
+		},
+		{
+			name: "not a mapping",
+			in:   "- a\n",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := AppendFlag([]byte(tt.in), flag)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:\n%s", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendFlagKeepsLayout(t *testing.T) {
+	flag := Flag{Key: "new", Name: "New", Type: "BOOLEAN_FLAG_TYPE", Enabled: true}
+
+	for _, path := range []string{"testdata/roundtrip/foot-comments.golden", "testdata/roundtrip/flag-comments.golden", "testdata/roundtrip/every-position.golden"} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			in, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := AppendFlag(in, flag)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Taking the new flag's lines back out must leave the file as it
+			// was, so the diff is only the flag.
+			lines := strings.Split(string(out), "\n")
+			at := slices.Index(lines, "    - key: new")
+			if at < 0 || at+4 > len(lines) {
+				t.Fatalf("new flag not found:\n%s", out)
+			}
+			rest := slices.Delete(slices.Clone(lines), at, at+4)
+			if got := strings.Join(rest, "\n"); got != string(in) {
+				t.Errorf("changed more than the new flag:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestSetNamespaceKey(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "key replaced",
+			in:   "namespace:\n    key: old # renamed soon\n    name: Old\n",
+			want: "namespace:\n    key: new # renamed soon\n    name: Old\n",
+		},
+		{
+			name: "quoted key written plain",
+			in:   "namespace:\n    key: \"old\"\n    name: Old\n",
+			want: "namespace:\n    key: new\n    name: Old\n",
+		},
+		{
+			name: "no namespace key left alone",
+			in:   "flags: []\n",
+			want: "flags: []\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := SetNamespaceKey([]byte(tt.in), "new")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNamespaceKeyKeepsLayout(t *testing.T) {
+	for _, path := range []string{"testdata/roundtrip/foot-comments.golden", "testdata/roundtrip/flag-comments.golden", "testdata/roundtrip/every-position.golden"} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			in, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := SetNamespaceKey(in, "renamed")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Only the namespace key line may change.
+			inLines, outLines := strings.Split(string(in), "\n"), strings.Split(string(out), "\n")
+			if len(inLines) != len(outLines) {
+				t.Fatalf("line count changed:\n%s", out)
+			}
+			changed := 0
+			for i := range inLines {
+				if inLines[i] != outLines[i] {
+					changed++
+					if !strings.HasPrefix(outLines[i], "    key: renamed") {
+						t.Errorf("line %d changed: %q", i+1, outLines[i])
+					}
+				}
+			}
+			if changed != 1 {
+				t.Errorf("got %d changed lines, want 1:\n%s", changed, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Package flagfile models the files under flags/: the Flipt features files,
+// the access.yml files the ACL data is generated from, and shared segments.
+// It reads them from a flags directory and writes them in the canonical form
+// flagctl lint checks for.
+package flagfile
+
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------------------------------------------------------
+// Types — Flipt feature flag file schema
+// ---------------------------------------------------------------------------
+
+// Fields are declared in the order Flipt exports them (see FeaturesOrder), and
+// optional ones are omitted when empty, so encoding a value gives a canonical
+// file.
+
+type Features struct {
+	Namespace Namespace `yaml:"namespace"`
+	Flags     []Flag    `yaml:"flags,omitempty"`
+	Segments  []Segment `yaml:"segments,omitempty"`
+}
+
+type Namespace struct {
+	Key         string `yaml:"key"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+}
+
+type Flag struct {
+	Key         string    `yaml:"key"`
+	Name        string    `yaml:"name"`
+	Type        string    `yaml:"type"`
+	Description string    `yaml:"description,omitempty"`
+	Enabled     bool      `yaml:"enabled"`
+	Metadata    any       `yaml:"metadata,omitempty"`
+	Variants    []Variant `yaml:"variants,omitempty"`
+	Rules       []Rule    `yaml:"rules,omitempty"`
+	Rollouts    []Rollout `yaml:"rollouts,omitempty"`
+}
+
+type Variant struct {
+	Default     bool   `yaml:"default,omitempty"`
+	Key         string `yaml:"key"`
+	Name        string `yaml:"name,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Attachment  any    `yaml:"attachment,omitempty"`
+}
+
+type Rule struct {
+	Segment       *SegmentRef    `yaml:"segment,omitempty"`
+	Distributions []Distribution `yaml:"distributions,omitempty"`
+}
+
+type Distribution struct {
+	Variant string  `yaml:"variant"`
+	Rollout float64 `yaml:"rollout"`
+}
+
+type Rollout struct {
+	Segment   *SegmentRef `yaml:"segment,omitempty"`
+	Threshold *Threshold  `yaml:"threshold,omitempty"`
+}
+
+type SegmentRef struct {
+	Key      string   `yaml:"key,omitempty"`
+	Keys     []string `yaml:"keys,omitempty"`
+	Operator string   `yaml:"operator,omitempty"`
+	Value    any      `yaml:"value,omitempty"`
+}
+
+type Threshold struct {
+	Percentage float64 `yaml:"percentage"`
+	Value      any     `yaml:"value,omitempty"`
+}
+
+type Segment struct {
+	Key         string       `yaml:"key"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description,omitempty"`
+	Constraints []Constraint `yaml:"constraints,omitempty"`
+	MatchType   string       `yaml:"match_type"`
+}
+
+type Constraint struct {
+	Type        string `yaml:"type"`
+	Property    string `yaml:"property"`
+	Operator    string `yaml:"operator"`
+	Value       string `yaml:"value,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// SegmentKeys returns every segment key the flag's rollouts and rules
+// reference, through either the single `key` or the multi `keys` syntax.
+func (f Flag) SegmentKeys() []string {
+	var refs []*SegmentRef
+	for _, r := range f.Rollouts {
+		refs = append(refs, r.Segment)
+	}
+	for _, r := range f.Rules {
+		refs = append(refs, r.Segment)
+	}
+
+	var keys []string
+	for _, ref := range refs {
+		if ref == nil {
+			continue
+		}
+		if ref.Key != "" {
+			keys = append(keys, ref.Key)
+		}
+		keys = append(keys, ref.Keys...)
+	}
+	return keys
+}
+
+// ---------------------------------------------------------------------------
+// Access — access.yml and flags/access/<namespace>.yml
+// ---------------------------------------------------------------------------
+
+type Access struct {
+	Writers         []AccessGrant `yaml:"writers"`
+	Readers         []AccessGrant `yaml:"readers,omitempty"`
+	Togglers        []AccessGrant `yaml:"togglers,omitempty"`
+	ProdSelfService bool          `yaml:"prodSelfService,omitempty"`
+}
+
+// AccessGrant is one team entry in a role list: either a bare team slug, or a
+// mapping with a team and an optional expires timestamp for temporary access.
+type AccessGrant struct {
+	Team    string     `yaml:"team"`
+	Expires *time.Time `yaml:"expires,omitempty"`
+}
+
+func (g *AccessGrant) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&g.Team)
+	}
+
+	type plain AccessGrant
+	return value.Decode((*plain)(g))
+}
+
+// MarshalYAML writes a permanent grant as a bare team slug.
+func (g AccessGrant) MarshalYAML() (any, error) {
+	if g.Expires == nil {
+		return g.Team, nil
+	}
+
+	type plain AccessGrant
+	return plain(g), nil
+}
+
+// Teams returns the team slugs of grants, in order.
+func Teams(grants []AccessGrant) []string {
+	teams := make([]string, 0, len(grants))
+	for _, g := range grants {
+		teams = append(teams, g.Team)
+	}
+	return teams
+}
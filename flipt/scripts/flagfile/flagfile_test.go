@@ -0,0 +1,119 @@
+package flagfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAccessGrantUnmarshal(t *testing.T) {
+	expires := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   string
+		want AccessGrant
+	}{
+		{"bare slug", "team-a", AccessGrant{Team: "team-a"}},
+		{"mapping", "team: team-a", AccessGrant{Team: "team-a"}},
+		{"temporary", "team: team-a\nexpires: 2030-01-31T00:00:00Z", AccessGrant{Team: "team-a", Expires: &expires}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got AccessGrant
+			if err := yaml.Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		flag Flag
+		want []string
+	}{
+		{"none", Flag{Key: "f"}, nil},
+		{
+			name: "rollout key",
+			flag: Flag{Rollouts: []Rollout{{Segment: &SegmentRef{Key: "a"}}, {Threshold: &Threshold{Percentage: 50}}}},
+			want: []string{"a"},
+		},
+		{
+			name: "rule keys",
+			flag: Flag{Rules: []Rule{{Segment: &SegmentRef{Keys: []string{"a", "b"}, Operator: "AND_SEGMENT_OPERATOR"}}}},
+			want: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.SegmentKeys(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		shared, env string
+		want        Access
+		sources     int
+	}{
+		{name: "neither"},
+		{
+			name:    "shared only",
+			shared:  "writers:\n    - team-a\nreaders:\n    - team-b\n",
+			want:    Access{Writers: []AccessGrant{{Team: "team-a"}}, Readers: []AccessGrant{{Team: "team-b"}}},
+			sources: 1,
+		},
+		{
+			name:    "env replaces the lists it sets",
+			shared:  "writers:\n    - team-a\nreaders:\n    - team-b\n",
+			env:     "writers:\n    - team-c\n",
+			want:    Access{Writers: []AccessGrant{{Team: "team-c"}}, Readers: []AccessGrant{{Team: "team-b"}}},
+			sources: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagsDir := t.TempDir()
+			nsDir := filepath.Join(flagsDir, "dev", "my-service")
+			for path, content := range map[string]string{SharedAccessPath(flagsDir, nsDir): tt.shared, filepath.Join(nsDir, "access.yml"): tt.env} {
+				if content == "" {
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, sources, err := LoadAccess(flagsDir, nsDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if len(sources) != tt.sources {
+				t.Errorf("read %q, want %d files", sources, tt.sources)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package flagfile
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------------------------------------------------------
+// KeyOrder — the order Flipt exports keys in
+// ---------------------------------------------------------------------------
+
+// KeyOrder is the order Flipt exports a mapping's keys in, along with the
+// orders for the mappings under each key (under each item, for sequences).
+// Keys it doesn't list keep their relative order after the ones it does.
+type KeyOrder struct {
+	Keys   []string
+	Nested map[string]*KeyOrder
+}
+
+var SegmentOrder = &KeyOrder{
+	Keys: []string{"key", "name", "description", "constraints", "match_type"},
+	Nested: map[string]*KeyOrder{
+		"constraints": {Keys: []string{"type", "property", "operator", "value", "description"}},
+	},
+}
+
+var segmentRefOrder = &KeyOrder{Keys: []string{"key", "keys", "operator", "value"}}
+
+var FeaturesOrder = &KeyOrder{
+	Keys: []string{"version", "namespace", "flags", "segments"},
+	Nested: map[string]*KeyOrder{
+		"namespace": {Keys: []string{"key", "name", "description"}},
+		"flags": {
+			Keys: []string{"key", "name", "type", "description", "enabled", "metadata", "variants", "rules", "rollouts"},
+			Nested: map[string]*KeyOrder{
+				"variants": {Keys: []string{"default", "key", "name", "description", "attachment"}},
+				"rules": {
+					Keys: []string{"segment", "rank", "distributions"},
+					Nested: map[string]*KeyOrder{
+						"segment":       segmentRefOrder,
+						"distributions": {Keys: []string{"variant", "rollout"}},
+					},
+				},
+				"rollouts": {
+					Keys: []string{"description", "segment", "threshold"},
+					Nested: map[string]*KeyOrder{
+						"segment":   segmentRefOrder,
+						"threshold": {Keys: []string{"percentage", "value"}},
+					},
+				},
+			},
+		},
+		"segments": SegmentOrder,
+	},
+}
+
+// Rank is a key's position in the order, with unlisted keys last.
+func (o *KeyOrder) Rank(key string) int {
+	if i := slices.Index(o.Keys, key); i >= 0 {
+		return i
+	}
+	return len(o.Keys)
+}
+
+// Child returns the order for the mapping under key, or nil if there isn't
+// one (or o is nil).
+func (o *KeyOrder) Child(key string) *KeyOrder {
+	if o == nil {
+		return nil
+	}
+	return o.Nested[key]
+}
+
+// sortKeys reorders every mapping under node to match order.
+func sortKeys(node *yaml.Node, order *KeyOrder) {
+	if order == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			sortKeys(child, order)
+		}
+	case yaml.MappingNode:
+		pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return order.Rank(pairs[i][0].Value) < order.Rank(pairs[j][0].Value)
+		})
+
+		node.Content = node.Content[:0]
+		for _, pair := range pairs {
+			node.Content = append(node.Content, pair[0], pair[1])
+			sortKeys(pair[1], order.Child(pair[0].Value))
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Format — canonical YAML re-serialization (4-space indent)
+// ---------------------------------------------------------------------------
+
+// Format re-encodes data in canonical form, with mapping keys in order (if it
+// isn't nil) and comments kept.
+func Format(data []byte, order *KeyOrder) ([]byte, error) {
+	return Edit(data, order, func(*yaml.Node) (bool, error) { return true, nil })
+}
+
+// Edit applies edit to the document in data and re-encodes it the way Format
+// does, so the comments and blank lines around whatever edit changed stay
+// where they were written. If edit reports no change, data is returned as it
+// was.
+func Edit(data []byte, order *KeyOrder, edit func(doc *yaml.Node) (bool, error)) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	if changed, err := edit(&node); err != nil || !changed {
+		return data, err
+	}
+
+	sortKeys(&node, order)
+
+	if len(node.Content) > 0 {
+		src := &commentLines{lines: strings.Split(string(data), "\n"), claimed: make(map[int]bool)}
+		keepBlankLines(&node, src)
+
+		root := node.Content[0]
+		if rest := placeFootComments(root, src); len(rest) > 0 {
+			var texts []string
+			for _, c := range rest {
+				texts = append(texts, c.text)
+			}
+			// Whatever trails the last top-level value closes the file, which
+			// re-encodes at column 0 and reads back in the same place.
+			if root.Kind == yaml.MappingNode && len(root.Content) > 0 {
+				last := root.Content[len(root.Content)-2]
+				last.FootComment = joinComments(append(texts, last.FootComment)...)
+			} else {
+				node.FootComment = joinComments(texts...)
+			}
+		}
+	}
+
+	out, err := Encode(&node)
+	if err != nil {
+		return nil, err
+	}
+
+	// A blank line kept in a head comment is written indented; the spaces
+	// mean nothing, and lint would flag them as trailing whitespace.
+	return whitespaceLine.ReplaceAll(out, nil), nil
+}
+
+var whitespaceLine = regexp.MustCompile(`(?m)^[ \t]+$`)
+
+// Encode serialises v (a value, or a *yaml.Node being edited in place) with
+// the 4-space indent Format uses, quoting YAML-significant characters in
+// values.
+func Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(4)
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	encoder.Close()
+	return buf.Bytes(), nil
+}
+
+// commentLines is a document's source, for finding where the comments yaml.v3
+// has attached to nodes were written.
+type commentLines struct {
+	lines   []string
+	claimed map[int]bool
+}
+
+// find returns where comment was written, looking for its first line from
+// line after onwards and claiming it so a repeated comment is found in its own
+// place. A comment that can't be found keeps column.
+func (s *commentLines) find(comment string, after, column int) footComment {
+	c := footComment{text: comment, column: column, blankAfter: true}
+
+	first, _, _ := strings.Cut(comment, "\n")
+	for i := max(after, 0); i < len(s.lines); i++ {
+		if s.claimed[i] || strings.TrimSpace(s.lines[i]) != strings.TrimSpace(first) {
+			continue
+		}
+
+		s.claimed[i] = true
+		c.column = strings.Index(s.lines[i], "#") + 1
+		if s.separated(i) {
+			c.text = "\n" + c.text
+		}
+		end := i + strings.Count(comment, "\n") + 1
+		c.blankAfter = end >= len(s.lines) || strings.TrimSpace(s.lines[end]) == ""
+		break
+	}
+
+	return c
+}
+
+// separated reports whether the comment starting on line i is set off by a
+// blank line from a comment at another indent above it, which yaml.v3 drops:
+// say one closing a flag's rollouts and another introducing the next flag.
+// (Between comments at the same indent the encoder writes one itself.)
+func (s *commentLines) separated(i int) bool {
+	if i < 2 || i >= len(s.lines) || strings.TrimSpace(s.lines[i-1]) != "" {
+		return false
+	}
+
+	above := i - 2
+	for above > 0 && strings.TrimSpace(s.lines[above]) == "" {
+		above--
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(s.lines[above]), "#") &&
+		strings.Index(s.lines[above], "#") != strings.Index(s.lines[i], "#")
+}
+
+// lastLine is the last line node or anything under it starts on.
+func lastLine(node *yaml.Node) int {
+	last := node.Line
+	for _, child := range node.Content {
+		last = max(last, lastLine(child))
+	}
+	return last
+}
+
+// footComment is a foot comment, the column it was written at, and whether a
+// blank line followed it.
+type footComment struct {
+	text       string
+	column     int
+	blankAfter bool
+}
+
+// placeFootComments puts every foot comment under node back at the indent it
+// was written at. yaml.v3 attaches a comment after a block to the deepest key
+// above it, wherever it was written, and writes a key's foot comment at that
+// key's indent, so a comment closing (say) a flag's rollouts would move into
+// the last rollout. Each comment goes on the innermost enclosing key at or
+// left of its column instead; one left of every key in a sequence item heads
+// the next item. Comments left of node itself are returned for the caller to
+// place.
+func placeFootComments(node *yaml.Node, src *commentLines) []footComment {
+	var pending []footComment
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			comments := placeFootComments(value, src)
+			if key.FootComment != "" {
+				comments = append(comments, src.find(key.FootComment, lastLine(value), key.Column))
+				key.FootComment = ""
+			}
+
+			// Only the last key's comments can belong further out: anything
+			// after an earlier key is still inside this mapping.
+			var last footComment
+			for _, c := range comments {
+				if c.column < key.Column && i+2 == len(node.Content) {
+					pending = append(pending, c)
+				} else {
+					key.FootComment = joinComments(key.FootComment, c.text)
+					last = c
+				}
+			}
+
+			// The encoder puts a blank line between a foot comment and a next
+			// key at its indent, so one written right above the next key heads
+			// it instead.
+			if key.FootComment != "" && i+2 < len(node.Content) && !last.blankAfter {
+				next := node.Content[i+2]
+				next.HeadComment = joinComments(key.FootComment, next.HeadComment)
+				key.FootComment = ""
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			comments := placeFootComments(item, src)
+			if i+1 == len(node.Content) {
+				pending = comments
+				break
+			}
+
+			next := node.Content[i+1]
+			for j := len(comments) - 1; j >= 0; j-- {
+				next.HeadComment = joinComments(comments[j].text, next.HeadComment)
+			}
+		}
+	}
+
+	if node.FootComment != "" {
+		pending = append(pending, src.find(node.FootComment, lastLine(node), node.Column))
+		node.FootComment = ""
+	}
+
+	return pending
+}
+
+// keepBlankLines keeps the blank line yaml.v3 drops between a foot comment
+// and the head comment of a node further out (see commentLines.separated).
+func keepBlankLines(node *yaml.Node, src *commentLines) {
+	if node.HeadComment != "" {
+		// src.lines is 0-indexed, node.Line 1-indexed.
+		if start := node.Line - 1 - (strings.Count(node.HeadComment, "\n") + 1); src.separated(start) {
+			node.HeadComment = "\n" + node.HeadComment
+		}
+	}
+
+	for _, child := range node.Content {
+		keepBlankLines(child, src)
+	}
+}
+
+// joinComments concatenates comment blocks, skipping empty ones.
+func joinComments(comments ...string) string {
+	var blocks []string
+	for _, c := range comments {
+		if c != "" {
+			blocks = append(blocks, c)
+		}
+	}
+	return strings.Join(blocks, "\n")
+}
@@ -0,0 +1,282 @@
+package flagfile
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Run with `make flags-test`, or `go test ./flagfile [-update]` from
+// flipt/scripts.
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata/roundtrip from the current output")
+
+// comments returns every comment in a YAML document, sorted.
+func comments(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		for _, c := range []string{n.HeadComment, n.LineComment, n.FootComment} {
+			for _, line := range strings.Split(c, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					out = append(out, line)
+				}
+			}
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(&node)
+
+	slices.Sort(out)
+	return out
+}
+
+// commentPositions returns the comment lines in a YAML document in document
+// order, each with its depth (how many of the blocks open above it it's
+// inside) and whether a blank line comes before it. Depth rather than column,
+// so re-indenting doesn't count as moving a comment. A sequence item is a
+// block but the sequence's dashes aren't: a comment after the last item can
+// only be written on the key around the sequence, and is still outside every
+// item there.
+func commentPositions(data []byte) []string {
+	var out []string
+	var open []int // the indents of the blocks open at this line
+	blank := false
+
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		switch {
+		case trimmed == "":
+			blank = true
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			depth := 0
+			for _, block := range open {
+				if block <= indent {
+					depth++
+				}
+			}
+			position := fmt.Sprintf("depth %d: %s", depth, trimmed)
+			if blank {
+				position = "after a blank line, " + position
+			}
+			out = append(out, position)
+		default:
+			for len(open) > 0 && open[len(open)-1] >= indent {
+				open = open[:len(open)-1]
+			}
+			rest := line[indent:]
+			for strings.HasPrefix(rest, "- ") {
+				indent, rest = indent+2, rest[2:]
+			}
+			open = append(open, indent)
+		}
+		blank = false
+	}
+
+	return out
+}
+
+// checkRoundTrip formats data and checks the result keeps every comment, in
+// the same place, and is already canonical, so a second --fix changes
+// nothing.
+func checkRoundTrip(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	once, err := Format(data, FeaturesOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := comments(t, once), comments(t, data); !slices.Equal(got, want) {
+		t.Errorf("comments changed\n got: %q\nwant: %q", got, want)
+	}
+	if got, want := commentPositions(once), commentPositions(data); !slices.Equal(got, want) {
+		t.Errorf("comments moved\n got: %q\nwant: %q", got, want)
+	}
+
+	twice, err := Format(once, FeaturesOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(once, twice) {
+		t.Errorf("formatting isn't stable, second pass gave:\n%s", twice)
+	}
+
+	return once
+}
+
+// TestRoundTripGolden formats each fixture in testdata/roundtrip and compares
+// it with the .golden file beside it.
+func TestRoundTripGolden(t *testing.T) {
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "roundtrip", "*.yml"))
+	if len(inputs) == 0 {
+		t.Fatal("no fixtures in testdata/roundtrip")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".yml")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := checkRoundTrip(t, data)
+
+			golden := strings.TrimSuffix(input, ".yml") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s (re-run with -update if intended):\n%s", filepath.Base(golden), got)
+			}
+		})
+	}
+}
+
+// TestRoundTripFeatures checks every features file in flags/ formats to
+// itself. flags-lint keeps them canonical, so each file is its own golden.
+func TestRoundTripFeatures(t *testing.T) {
+	flagsDir := filepath.Join("..", "..", "..", "flags")
+
+	var files []string
+	for _, nsDir := range NamespaceDirs(flagsDir) {
+		files = append(files, FeaturesFiles(nsDir)...)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no features files found under %s", flagsDir)
+	}
+
+	for _, path := range files {
+		rel, _ := filepath.Rel(flagsDir, path)
+		t.Run(filepath.ToSlash(rel), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := checkRoundTrip(t, data)
+			if !bytes.Equal(bytes.TrimRight(got, "\n"), bytes.TrimRight(data, "\n")) {
+				t.Errorf("not canonical, --fix would rewrite it as:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		order *KeyOrder
+		want  string
+	}{
+		{
+			name: "two-space indent",
+			in:   "namespace:\n  key: a\n  name: A\n",
+			want: "namespace:\n    key: a\n    name: A\n",
+		},
+		{
+			name:  "keys in export order",
+			in:    "namespace:\n    name: A\n    key: a\nversion: \"1.4\"\n",
+			order: FeaturesOrder,
+			want:  "version: \"1.4\"\nnamespace:\n    key: a\n    name: A\n",
+		},
+		{
+			name: "no order keeps keys",
+			in:   "writers:\n    - a\nreaders:\n    - b\n",
+			want: "writers:\n    - a\nreaders:\n    - b\n",
+		},
+		{
+			name:  "unlisted keys last",
+			in:    "custom: x\nkey: s\nmatch_type: ALL_MATCH_TYPE\nname: S\n",
+			order: SegmentOrder,
+			want:  "key: s\nname: S\nmatch_type: ALL_MATCH_TYPE\ncustom: x\n",
+		},
+		{
+			name:  "trailing comment stays last",
+			in:    "namespace:\n    key: a\n    name: A\n\n# end\n",
+			order: FeaturesOrder,
+			want:  "namespace:\n    key: a\n    name: A\n\n# end\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format([]byte(tt.in), tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{
+			name: "new namespace",
+			in:   Features{Namespace: Namespace{Key: "my-service", Name: "My service"}},
+			want: "namespace:\n    key: my-service\n    name: My service\n",
+		},
+		{
+			name: "permanent grants are bare slugs",
+			in:   Access{Writers: []AccessGrant{{Team: "team-a"}}, ProdSelfService: true},
+			want: "writers:\n    - team-a\nprodSelfService: true\n",
+		},
+		{
+			name: "significant characters are quoted",
+			in:   Namespace{Key: "a", Name: "a: b"},
+			want: "key: a\nname: 'a: b'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Encode(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			// Everything Encode writes must already pass the formatting check.
+			if canonical, err := Format(got, nil); err != nil || !bytes.Equal(canonical, got) {
+				t.Errorf("not canonical, Format gives:\n%s", canonical)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package flagfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FeaturesPatterns are the files Flipt reads a namespace's flags from (see
+// flags/*/flipt.yml).
+var FeaturesPatterns = []string{"features.yml", "features.yaml", "*.features.yml", "*.features.yaml"}
+
+// Environments lists the environments under flagsDir: each directory with a
+// flipt.yml, sorted.
+func Environments(flagsDir string) []string {
+	var envs []string
+
+	matches, _ := filepath.Glob(filepath.Join(flagsDir, "*", "flipt.yml"))
+	for _, match := range matches {
+		envs = append(envs, filepath.Base(filepath.Dir(match)))
+	}
+
+	sort.Strings(envs)
+	return envs
+}
+
+// NamespaceDirs lists the flags/<env>/<namespace>/ directories that have an
+// access.yml or a features file, sorted.
+func NamespaceDirs(flagsDir string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, name := range []string{"access.yml", "features.yml", "features.yaml"} {
+		matches, _ := filepath.Glob(filepath.Join(flagsDir, "*", "*", name))
+		for _, match := range matches {
+			if dir := filepath.Dir(match); !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// FeaturesFiles lists the Flipt feature files in a namespace directory.
+func FeaturesFiles(nsDir string) []string {
+	var files []string
+	for _, pattern := range FeaturesPatterns {
+		matches, _ := filepath.Glob(filepath.Join(nsDir, pattern))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return slices.Compact(files)
+}
+
+// LoadFeatures reads and decodes a features file.
+func LoadFeatures(path string) (Features, error) {
+	var f Features
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("%s: invalid YAML: %w", path, err)
+	}
+	return f, nil
+}
+
+// NamespaceKey returns the namespace.key a namespace directory's features
+// files declare, or "" if none do. The directory name may differ from the key
+// (e.g. directory "probation-in-court" → namespace "ProbationInCourt").
+func NamespaceKey(nsDir string) string {
+	for _, path := range FeaturesFiles(nsDir) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		// Only the key is decoded, so a file lint would reject elsewhere
+		// still names its namespace.
+		var f struct {
+			Namespace struct {
+				Key string `yaml:"key"`
+			} `yaml:"namespace"`
+		}
+		if yaml.Unmarshal(data, &f) == nil && f.Namespace.Key != "" {
+			return f.Namespace.Key
+		}
+	}
+	return ""
+}
+
+// SharedAccessPath is the namespace-level access definition every environment
+// inherits, keyed by the namespace's directory name.
+func SharedAccessPath(flagsDir, nsDir string) string {
+	return filepath.Join(flagsDir, "access", filepath.Base(nsDir)+".yml")
+}
+
+// LoadAccess reads a namespace's effective access for one environment: the
+// shared flags/access/<namespace>.yml, with any list set in the environment's
+// own access.yml replacing the shared one. It returns the files it read, which
+// is none if the namespace has neither.
+func LoadAccess(flagsDir, nsDir string) (Access, []string, error) {
+	var access Access
+	var sources []string
+
+	for _, path := range []string{SharedAccessPath(flagsDir, nsDir), filepath.Join(nsDir, "access.yml")} {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		sources = append(sources, path)
+		if err != nil {
+			return access, sources, err
+		}
+
+		// Decoding over the shared values only replaces the keys this file sets.
+		if err := yaml.Unmarshal(data, &access); err != nil {
+			return access, sources, fmt.Errorf("invalid YAML: %w", err)
+		}
+	}
+
+	return access, sources, nil
+}
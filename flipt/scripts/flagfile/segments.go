@@ -0,0 +1,165 @@
+package flagfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SharedSegment is a definition from flags/segments/, parsed both ways: the
+// struct to compare copies against, the node to inline.
+type SharedSegment struct {
+	Path    string
+	Segment Segment
+	node    *yaml.Node
+}
+
+// ---------------------------------------------------------------------------
+// LoadSegmentLibrary — reads the shared segment definitions
+// ---------------------------------------------------------------------------
+
+// LoadSegmentLibrary returns the shared segments in flagsDir/segments keyed
+// by segment key, and an error for each definition it had to skip.
+func LoadSegmentLibrary(flagsDir string) (map[string]SharedSegment, []error) {
+	library := make(map[string]SharedSegment)
+	var skipped []error
+
+	paths, _ := filepath.Glob(filepath.Join(flagsDir, "segments", "*.yml"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+
+		var doc yaml.Node
+		var seg Segment
+		if err := yaml.Unmarshal(data, &doc); err != nil || yaml.Unmarshal(data, &seg) != nil || len(doc.Content) == 0 {
+			skipped = append(skipped, fmt.Errorf("%s: invalid shared segment, run make flags-lint", path))
+			continue
+		}
+
+		if seg.Key != strings.TrimSuffix(filepath.Base(path), ".yml") {
+			skipped = append(skipped, fmt.Errorf("%s: key %q doesn't match the file name", path, seg.Key))
+			continue
+		}
+
+		node := doc.Content[0]
+		detach(node)
+		library[seg.Key] = SharedSegment{Path: path, Segment: seg, node: node}
+	}
+
+	return library, skipped
+}
+
+// detach drops the comments from a shared definition, which describe the
+// library entry rather than any namespace's copy of it, and its positions,
+// which are lines of the library file rather than of the file it's copied into.
+func detach(node *yaml.Node) {
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	node.Line, node.Column = 0, 0
+	for _, child := range node.Content {
+		detach(child)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// SyncSegments — inlines referenced shared segments into one features file
+// ---------------------------------------------------------------------------
+
+// SyncResult lists the shared segments SyncSegments inlined, found drifted
+// from their definition, and replaced with it.
+type SyncResult struct {
+	Inlined     []string
+	Drifted     []string
+	Overwritten []string
+}
+
+// SyncSegments copies every shared segment the features file in data
+// references but doesn't define into its segments, and reports copies that
+// no longer match their definition, replacing them if overwrite is set. It
+// returns data unchanged if there was nothing to inline or replace.
+func SyncSegments(data []byte, library map[string]SharedSegment, overwrite bool) ([]byte, SyncResult, error) {
+	var result SyncResult
+
+	var file Features
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, result, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, f := range file.Flags {
+		for _, key := range f.SegmentKeys() {
+			referenced[key] = true
+		}
+	}
+
+	out, err := Edit(data, FeaturesOrder, func(doc *yaml.Node) (bool, error) {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			return false, nil
+		}
+
+		root := doc.Content[0]
+		var segments *yaml.Node
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "segments" && root.Content[i+1].Kind == yaml.SequenceNode {
+				segments = root.Content[i+1]
+			}
+		}
+
+		local := make(map[string]int)
+		for i, seg := range file.Segments {
+			local[seg.Key] = i
+		}
+
+		keys := make([]string, 0, len(library))
+		for key := range library {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			shared := library[key]
+
+			if i, exists := local[key]; exists {
+				if reflect.DeepEqual(file.Segments[i], shared.Segment) {
+					continue
+				}
+
+				result.Drifted = append(result.Drifted, key)
+				if overwrite {
+					// The copy keeps the comments and position of the one
+					// it replaces, so they stay where they were.
+					old, replacement := segments.Content[i], *shared.node
+					replacement.HeadComment, replacement.LineComment, replacement.FootComment = old.HeadComment, old.LineComment, old.FootComment
+					replacement.Line, replacement.Column = old.Line, old.Column
+					segments.Content[i] = &replacement
+					result.Overwritten = append(result.Overwritten, key)
+				}
+				continue
+			}
+
+			if !referenced[key] {
+				continue
+			}
+
+			if segments == nil {
+				segments = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "segments"}, segments)
+			}
+			segments.Content = append(segments.Content, shared.node)
+			result.Inlined = append(result.Inlined, key)
+		}
+
+		return len(result.Inlined) > 0 || len(result.Overwritten) > 0, nil
+	})
+	if err != nil {
+		return nil, result, err
+	}
+	return out, result, nil
+}
@@ -0,0 +1,214 @@
+package flagfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files (relative path → content) under a temporary flags
+// directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	flagsDir := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(flagsDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return flagsDir
+}
+
+func TestLoadSegmentLibrary(t *testing.T) {
+	flagsDir := writeTree(t, map[string]string{
+		"segments/go-live.yml":  "# Probation go-live\nkey: go-live\nname: Go live\nmatch_type: ALL_MATCH_TYPE\n",
+		"segments/misnamed.yml": "key: other\nname: Other\nmatch_type: ALL_MATCH_TYPE\n",
+		"segments/broken.yml":   "key: [\n",
+		"segments/notes.txt":    "not a segment\n",
+	})
+
+	library, skipped := LoadSegmentLibrary(flagsDir)
+
+	keys := make([]string, 0, len(library))
+	for key := range library {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if want := []string{"go-live"}; !slices.Equal(keys, want) {
+		t.Errorf("got %q, want %q", keys, want)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("got skipped %v, want 2", skipped)
+	}
+	if got := library["go-live"].node.HeadComment; got != "" {
+		t.Errorf("library comment kept: %q", got)
+	}
+}
+
+func TestSyncSegments(t *testing.T) {
+	flagsDir := writeTree(t, map[string]string{
+		"segments/go-live.yml": "key: go-live\nname: Go live\nmatch_type: ALL_MATCH_TYPE\n",
+		"segments/unused.yml":  "key: unused\nname: Unused\nmatch_type: ALL_MATCH_TYPE\n",
+	})
+	library, skipped := LoadSegmentLibrary(flagsDir)
+	if len(skipped) > 0 {
+		t.Fatal(skipped)
+	}
+
+	const referencing = `namespace:
+    key: a
+    name: A
+flags:
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+      rollouts:
+        - segment:
+            key: go-live
+            value: true
+`
+
+	tests := []struct {
+		name      string
+		in        string
+		overwrite bool
+		want      string
+		result    SyncResult
+	}{
+		{
+			name: "referenced segment inlined",
+			in:   referencing,
+			want: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ALL_MATCH_TYPE
+`,
+			result: SyncResult{Inlined: []string{"go-live"}},
+		},
+		{
+			name: "matching copy left alone",
+			in: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ALL_MATCH_TYPE
+`,
+			want: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ALL_MATCH_TYPE
+`,
+		},
+		{
+			name: "drifted copy reported",
+			in: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ANY_MATCH_TYPE
+`,
+			want: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ANY_MATCH_TYPE
+`,
+			result: SyncResult{Drifted: []string{"go-live"}},
+		},
+		{
+			name: "drifted copy overwritten",
+			in: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ANY_MATCH_TYPE
+`,
+			overwrite: true,
+			want: referencing + `segments:
+    - key: go-live
+      name: Go live
+      match_type: ALL_MATCH_TYPE
+`,
+			result: SyncResult{Drifted: []string{"go-live"}, Overwritten: []string{"go-live"}},
+		},
+		{
+			name: "unreferenced segment not inlined",
+			in:   "namespace:\n    key: a\n    name: A\n",
+			want: "namespace:\n    key: a\n    name: A\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, result, err := SyncSegments([]byte(tt.in), library, tt.overwrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out, tt.want)
+			}
+			if !reflect.DeepEqual(result, tt.result) {
+				t.Errorf("result: got %+v, want %+v", result, tt.result)
+			}
+		})
+	}
+}
+
+func TestSyncSegmentsKeepsLayout(t *testing.T) {
+	flagsDir := writeTree(t, map[string]string{
+		"segments/go-live.yml": "# Probation go-live\nkey: go-live\nname: Go live\nmatch_type: ALL_MATCH_TYPE\n",
+		"segments/testers.yml": "key: testers\nname: Testers\nmatch_type: ALL_MATCH_TYPE\n",
+	})
+	library, skipped := LoadSegmentLibrary(flagsDir)
+	if len(skipped) > 0 {
+		t.Fatal(skipped)
+	}
+
+	const in = `# Owned by the probation team
+namespace:
+    key: a
+    name: A
+flags:
+    # Launch switch
+    - key: f
+      name: F
+      type: BOOLEAN_FLAG_TYPE
+      rollouts:
+        - segment:
+            key: go-live
+            value: true
+        # Testers first
+        - segment:
+            key: testers
+            value: true
+segments:
+    # Kept in step with segments/testers.yml
+    - key: testers
+      name: Testers
+      match_type: ANY_MATCH_TYPE
+`
+
+	out, result, err := SyncSegments([]byte(in), library, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SyncResult{Inlined: []string{"go-live"}, Drifted: []string{"testers"}, Overwritten: []string{"testers"}}); !reflect.DeepEqual(result, want) {
+		t.Errorf("result: got %+v, want %+v", result, want)
+	}
+
+	// Only the drifted line changes and the inlined segment is added; every
+	// comment and blank line stays where it was.
+	want := strings.Replace(in, "      match_type: ANY_MATCH_TYPE\n", "      match_type: ALL_MATCH_TYPE\n", 1) + `    - key: go-live
+      name: Go live
+      match_type: ALL_MATCH_TYPE
+`
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -0,0 +1,158 @@
+// Package cli holds the terminal helpers the flag commands share: coloured
+// output, interactive prompts, and the zap logger setup.
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ANSI colour codes, all empty once DisableColour has been called
+var (
+	Bold   = "\033[1m"
+	Cyan   = "\033[36m"
+	Green  = "\033[32m"
+	Yellow = "\033[33m"
+	Red    = "\033[31m"
+	Reset  = "\033[0m"
+)
+
+// colour is whether NewLogger colours log levels.
+var colour = true
+
+// DisableColour turns off the colour codes above and in log output, for
+// terminals and CI logs that don't render them.
+func DisableColour() {
+	Bold, Cyan, Green, Yellow, Red, Reset = "", "", "", "", "", ""
+	colour = false
+}
+
+var scanner = bufio.NewScanner(os.Stdin)
+
+func Info(msg string) { fmt.Printf("%s%s%s\n", Cyan, msg, Reset) }
+func OK(msg string)   { fmt.Printf("%s%s%s\n", Green, msg, Reset) }
+func Warn(msg string) { fmt.Printf("%s%s%s\n", Yellow, msg, Reset) }
+func Fail(msg string) { fmt.Fprintf(os.Stderr, "%s%s%s\n", Red, msg, Reset) }
+
+// Exit prints msg as a failure and exits with code.
+func Exit(code int, msg string) {
+	Fail(msg)
+	os.Exit(code)
+}
+
+func Prompt(label string, defaultValue string) string {
+	for {
+		if defaultValue != "" {
+			fmt.Printf("%s%s%s [%s%s%s]: ", Bold, label, Reset, Cyan, defaultValue, Reset)
+		} else {
+			fmt.Printf("%s%s%s: ", Bold, label, Reset)
+		}
+
+		scanner.Scan()
+		input := strings.TrimSpace(scanner.Text())
+
+		if input == "" {
+			input = defaultValue
+		}
+
+		if input != "" {
+			return input
+		}
+
+		Fail("This field is required.")
+	}
+}
+
+func OptionalPrompt(label string) string {
+	fmt.Printf("%s%s%s: ", Bold, label, Reset)
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text())
+}
+
+func PromptList(label string) []string {
+	var items []string
+
+	for {
+		prefix := fmt.Sprintf("  %d. ", len(items)+1)
+		if len(items) == 0 {
+			fmt.Printf("%s%s%s\n", Bold, label, Reset)
+		}
+
+		fmt.Printf("%s", prefix)
+		scanner.Scan()
+		input := strings.TrimSpace(scanner.Text())
+
+		if input == "" {
+			if len(items) == 0 {
+				Fail("At least one entry is required.")
+				continue
+			}
+			return items
+		}
+
+		items = append(items, input)
+		Info("  (press enter to finish)")
+	}
+}
+
+// Confirm asks a yes/no question, taking an empty answer as defaultYes.
+func Confirm(label string, defaultYes bool) bool {
+	fmt.Printf("%s%s%s ", Bold, label, Reset)
+	scanner.Scan()
+	input := strings.TrimSpace(scanner.Text())
+
+	if input == "" {
+		return defaultYes
+	}
+
+	return strings.ToLower(input[:1]) == "y"
+}
+
+// ListFlag collects a repeatable string flag.
+type ListFlag []string
+
+func (f *ListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *ListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// LogFormats are the encodings NewLoggerFormat accepts.
+var LogFormats = []string{"console", "json"}
+
+// NewLogger returns the console logger the non-interactive commands log
+// through.
+func NewLogger() *zap.Logger {
+	logger, _ := NewLoggerFormat("console")
+	return logger
+}
+
+// NewLoggerFormat returns a logger writing one of LogFormats: console for
+// people, json for log collectors.
+func NewLoggerFormat(format string) (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.EncoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02T15:04:05Z")
+	cfg.DisableCaller = true
+	cfg.DisableStacktrace = true
+
+	switch format {
+	case "console":
+		cfg.Encoding = "console"
+		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		if colour {
+			cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+	case "json":
+		cfg.Encoding = "json"
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want %s)", format, strings.Join(LogFormats, " or "))
+	}
+
+	return cfg.Build()
+}
@@ -0,0 +1,96 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// Run with `make flags-bench`, or `go test -run '^$' -bench . ./lint` from
+// flipt/scripts.
+
+// syntheticTree writes a flags directory of namespaces namespaces, each with
+// a few flags and a segment in every environment, that lints clean.
+func syntheticTree(b *testing.B, namespaces int) string {
+	b.Helper()
+
+	flagsDir := b.TempDir()
+	for _, env := range []string{"dev", "preprod", "prod"} {
+		for n := range namespaces {
+			dir := filepath.Join(flagsDir, env, fmt.Sprintf("namespace-%04d", n))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				b.Fatal(err)
+			}
+
+			features := fmt.Sprintf(`namespace:
+    key: namespace-%04d
+    name: Namespace %d
+flags:
+    - key: new-journey
+      name: New journey
+      type: BOOLEAN_FLAG_TYPE
+      enabled: true
+      rollouts:
+        - segment:
+            key: test-users
+            value: true
+    - key: letter-template
+      name: Letter template
+      type: VARIANT_FLAG_TYPE
+      enabled: false
+      variants:
+        - default: true
+          key: legacy
+        - key: v2
+      rules:
+        - segment:
+            key: test-users
+          distributions:
+            - variant: v2
+              rollout: 100
+segments:
+    - key: test-users
+      name: Test users
+      constraints:
+        - type: STRING_COMPARISON_TYPE
+          property: username
+          operator: isoneof
+          value: '["USER_%d"]'
+      match_type: ALL_MATCH_TYPE
+`, n, n, n)
+			access := fmt.Sprintf("writers:\n    - team-%d\n", n%50)
+
+			if err := os.WriteFile(filepath.Join(dir, "features.yml"), []byte(features), 0644); err != nil {
+				b.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "access.yml"), []byte(access), 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	return flagsDir
+}
+
+// BenchmarkLint lints a 1,000-namespace tree sequentially and in parallel.
+func BenchmarkLint(b *testing.B) {
+	flagsDir := syntheticTree(b, 1000)
+	files := DiscoverFiles(flagsDir)
+
+	for i, issues := range Files(flagsDir, files, runtime.NumCPU()) {
+		if len(issues) > 0 {
+			b.Fatalf("synthetic file %s has issues: %v", files[i], issues)
+		}
+	}
+
+	for _, jobs := range slices.Compact(slices.Sorted(slices.Values([]int{1, 4, runtime.NumCPU()}))) {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			for b.Loop() {
+				Files(flagsDir, files, jobs)
+			}
+		})
+	}
+}
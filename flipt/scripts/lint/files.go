@@ -0,0 +1,221 @@
+package lint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------------------------------------------------------
+// Formatting — round-trip formatting check
+// ---------------------------------------------------------------------------
+
+// Formatting reports every line of data that differs from what
+// flagfile.Format would write, or the keys out of order if there are any.
+func Formatting(data []byte, order *flagfile.KeyOrder) []Issue {
+	canonical, err := flagfile.Format(data, order)
+	if err != nil {
+		return []Issue{Errorf("invalid YAML: %v", err)}
+	}
+
+	// Reordered keys would show up below as every moved line differing, so
+	// report the keys themselves instead.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err == nil {
+		if issues := keyOrderIssues(&node, order); len(issues) > 0 {
+			return issues
+		}
+	}
+
+	origLines := strings.Split(string(bytes.TrimRight(data, "\n")), "\n")
+	canonLines := strings.Split(string(bytes.TrimRight(canonical, "\n")), "\n")
+
+	var issues []Issue
+
+	if len(origLines) != len(canonLines) {
+		// Find extra blank lines by walking the original and flagging lines
+		// that don't appear in the canonical output.
+		ci := 0
+		for oi := 0; oi < len(origLines); oi++ {
+			if ci < len(canonLines) && origLines[oi] == canonLines[ci] {
+				ci++
+				continue
+			}
+			if strings.TrimSpace(origLines[oi]) == "" {
+				issues = append(issues, Errorf("formatting: line %d is an extra blank line", oi+1))
+			} else {
+				issues = append(issues, Errorf("formatting: line %d differs from canonical form", oi+1))
+			}
+		}
+		if len(issues) == 0 {
+			issues = append(issues, Errorf("formatting: expected %d lines, got %d", len(canonLines), len(origLines)))
+		}
+		return issues
+	}
+
+	for i := 0; i < len(origLines); i++ {
+		if origLines[i] != canonLines[i] {
+			issues = append(issues, Errorf("formatting: line %d differs from canonical form", i+1))
+		}
+	}
+
+	return issues
+}
+
+// keyOrderIssues reports each key that comes after one Flipt writes later.
+func keyOrderIssues(node *yaml.Node, order *flagfile.KeyOrder) []Issue {
+	if order == nil {
+		return nil
+	}
+
+	var issues []Issue
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			issues = append(issues, keyOrderIssues(child, order)...)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			for j := 0; j < i; j += 2 {
+				if earlier := node.Content[j]; order.Rank(earlier.Value) > order.Rank(key.Value) {
+					issues = append(issues, Errorf("formatting: line %d: %q should come before %q", key.Line, key.Value, earlier.Value))
+					break
+				}
+			}
+			issues = append(issues, keyOrderIssues(node.Content[i+1], order.Child(key.Value))...)
+		}
+	}
+	return issues
+}
+
+// KeyOrderFor returns the key order for a file lint checks, or nil for files
+// (access and ACL config) that Flipt doesn't export.
+func KeyOrderFor(flagsDir, path string) *flagfile.KeyOrder {
+	switch {
+	case filepath.Dir(path) == filepath.Join(flagsDir, "segments"):
+		return flagfile.SegmentOrder
+	case slices.Contains([]string{"features.yml", "features.yaml"}, filepath.Base(path)):
+		return flagfile.FeaturesOrder
+	}
+	return nil
+}
+
+// Fix reformats the file at path to canonical form, leaving it untouched if
+// it already is.
+func Fix(path string, order *flagfile.KeyOrder) error {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	canonical, err := flagfile.Format(original, order)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(bytes.TrimRight(original, "\n"), bytes.TrimRight(canonical, "\n")) {
+		return nil
+	}
+
+	return os.WriteFile(path, canonical, 0644)
+}
+
+// ---------------------------------------------------------------------------
+// Files — file discovery and the worker pool
+// ---------------------------------------------------------------------------
+
+// DiscoverFiles returns every file lint checks under flagsDir, sorted.
+func DiscoverFiles(flagsDir string) []string {
+	patterns := []string{
+		filepath.Join(flagsDir, "*", "*", "features.yml"),
+		filepath.Join(flagsDir, "*", "*", "features.yaml"),
+		filepath.Join(flagsDir, "*", "*", "access.yml"),
+		filepath.Join(flagsDir, "acl-config.yml"),
+		filepath.Join(flagsDir, "access", "*.yml"),
+		filepath.Join(flagsDir, "segments", "*.yml"),
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// File runs the checks for whichever kind of file path is.
+func File(flagsDir, path string) []Issue {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []Issue{Errorf("cannot read file: %v", err)}
+	}
+
+	return Content(flagsDir, path, data)
+}
+
+// Content runs File's checks over data as if it were the content of path, so
+// files can be checked before they're written.
+func Content(flagsDir, path string, data []byte) []Issue {
+	var fileIssues []Issue
+
+	basename := filepath.Base(path)
+	if basename == "access.yml" {
+		fileIssues = append(fileIssues, Access(path, data, readSharedAccess(flagsDir, path))...)
+	} else if filepath.Dir(path) == filepath.Join(flagsDir, "access") {
+		fileIssues = append(fileIssues, Access(path, data, nil)...)
+	} else if filepath.Dir(path) == filepath.Join(flagsDir, "segments") {
+		fileIssues = append(fileIssues, Segment(path, data)...)
+	} else if basename == "acl-config.yml" {
+		fileIssues = append(fileIssues, ACLConfig(path, data)...)
+	} else {
+		fileIssues = append(fileIssues, Features(data)...)
+	}
+
+	return append(fileIssues, Formatting(data, KeyOrderFor(flagsDir, path))...)
+}
+
+// ForEachFile calls fn for every file from a pool of jobs workers. fn gets the
+// file's index so callers can store results in order, keeping the report the
+// same however the work was scheduled.
+func ForEachFile(files []string, jobs int, fn func(i int, path string)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(jobs, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i, files[i])
+			}
+		}()
+	}
+
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// Files lints files in parallel, returning each one's issues at its index.
+func Files(flagsDir string, files []string, jobs int) [][]Issue {
+	results := make([][]Issue, len(files))
+	ForEachFile(files, jobs, func(i int, path string) {
+		results[i] = File(flagsDir, path)
+	})
+	return results
+}
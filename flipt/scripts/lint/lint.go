@@ -0,0 +1,371 @@
+// Package lint checks the files under flags/: structural validation of the
+// Flipt features files, access files, the ACL config and shared segments, and
+// that every file is already in the canonical form flagfile.Format writes.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/acl"
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------------------------------------------------------
+// Issue — a single lint finding
+// ---------------------------------------------------------------------------
+
+type Level int
+
+const (
+	Error Level = iota
+	Warning
+)
+
+type Issue struct {
+	Message string
+	Level   Level
+}
+
+func Errorf(format string, args ...any) Issue {
+	return Issue{Message: fmt.Sprintf(format, args...), Level: Error}
+}
+
+func Warnf(format string, args ...any) Issue {
+	return Issue{Message: fmt.Sprintf(format, args...), Level: Warning}
+}
+
+// ---------------------------------------------------------------------------
+// Features — structural validation of a features.yml
+// ---------------------------------------------------------------------------
+
+// variantKeyRegex is the key format Flipt accepts for variants.
+var variantKeyRegex = regexp.MustCompile(`^[-_,A-Za-z0-9]+$`)
+
+func Features(data []byte) []Issue {
+	var file flagfile.Features
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return []Issue{Errorf("invalid YAML: %v", err)}
+	}
+
+	var issues []Issue
+
+	// Required namespace fields
+	if file.Namespace.Key == "" {
+		issues = append(issues, Errorf("missing required field: namespace.key"))
+	}
+	if file.Namespace.Name == "" {
+		issues = append(issues, Errorf("missing required field: namespace.name"))
+	}
+
+	// Build segment lookup
+	segmentKeys := make(map[string]bool)
+	segmentDuplicates := make(map[string]bool)
+	for _, seg := range file.Segments {
+		if segmentKeys[seg.Key] {
+			issues = append(issues, Errorf("segment %q: duplicate key", seg.Key))
+			segmentDuplicates[seg.Key] = true
+		}
+		segmentKeys[seg.Key] = true
+	}
+
+	// Build variant lookup per flag, check flags
+	flagKeys := make(map[string]bool)
+	referencedSegments := make(map[string]bool)
+
+	for _, f := range file.Flags {
+		// Required flag fields
+		if f.Key == "" {
+			issues = append(issues, Errorf("flag with empty key"))
+			continue
+		}
+		if f.Name == "" {
+			issues = append(issues, Errorf("flag %q: missing required field: name", f.Key))
+		}
+		if f.Type == "" {
+			issues = append(issues, Errorf("flag %q: missing required field: type", f.Key))
+		}
+
+		// Duplicate flag keys
+		if flagKeys[f.Key] {
+			issues = append(issues, Errorf("flag %q: duplicate key", f.Key))
+		}
+		flagKeys[f.Key] = true
+
+		// Invalid flag type
+		if f.Type != "" && f.Type != "BOOLEAN_FLAG_TYPE" && f.Type != "VARIANT_FLAG_TYPE" {
+			issues = append(issues, Errorf("flag %q: invalid type %q (must be BOOLEAN_FLAG_TYPE or VARIANT_FLAG_TYPE)", f.Key, f.Type))
+		}
+
+		// Type/field mismatch
+		if f.Type == "VARIANT_FLAG_TYPE" && len(f.Rollouts) > 0 {
+			issues = append(issues, Errorf("flag %q: variant flag cannot have rollouts", f.Key))
+		}
+		if f.Type == "BOOLEAN_FLAG_TYPE" {
+			if len(f.Variants) > 0 {
+				issues = append(issues, Errorf("flag %q: boolean flag cannot have variants", f.Key))
+			}
+			if len(f.Rules) > 0 {
+				issues = append(issues, Errorf("flag %q: boolean flag cannot have rules", f.Key))
+			}
+		}
+
+		// Collect segment refs and check they exist
+		for _, ref := range f.SegmentKeys() {
+			referencedSegments[ref] = true
+			if !segmentKeys[ref] {
+				issues = append(issues, Errorf("flag %q: references segment %q which is not defined", f.Key, ref))
+			}
+		}
+
+		// Check variant keys, and variant refs in rule distributions
+		if f.Type == "VARIANT_FLAG_TYPE" {
+			variantKeys := make(map[string]bool)
+			for _, v := range f.Variants {
+				if !variantKeyRegex.MatchString(v.Key) {
+					issues = append(issues, Errorf("flag %q: invalid variant key %q", f.Key, v.Key))
+				}
+				if variantKeys[v.Key] {
+					issues = append(issues, Errorf("flag %q: duplicate variant %q", f.Key, v.Key))
+				}
+				variantKeys[v.Key] = true
+			}
+			for _, rule := range f.Rules {
+				for _, dist := range rule.Distributions {
+					if !variantKeys[dist.Variant] {
+						issues = append(issues, Errorf("flag %q: distribution references variant %q which is not defined", f.Key, dist.Variant))
+					}
+				}
+			}
+		}
+	}
+
+	// Unused segments (warning)
+	for _, seg := range file.Segments {
+		if !referencedSegments[seg.Key] && !segmentDuplicates[seg.Key] {
+			issues = append(issues, Warnf("segment %q is defined but not referenced by any flag", seg.Key))
+		}
+	}
+
+	return issues
+}
+
+// ---------------------------------------------------------------------------
+// Access — validates an access.yml
+// ---------------------------------------------------------------------------
+
+// Access checks an access.yml, or a shared flags/access/<namespace>.yml.
+// shared is the shared definition an environment's access.yml inherits from,
+// or nil if there isn't one.
+func Access(path string, data []byte, shared *flagfile.Access) []Issue {
+	var file flagfile.Access
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return []Issue{Errorf("invalid YAML: %v", err)}
+	}
+
+	// Lists this file sets replace the shared ones, the rest are inherited.
+	effective := file
+	if shared != nil {
+		effective = *shared
+		yaml.Unmarshal(data, &effective)
+	}
+
+	if len(effective.Writers) == 0 {
+		return []Issue{Errorf("missing required field: writers")}
+	}
+
+	var issues []Issue
+
+	if shared != nil {
+		issues = append(issues, checkSharedAccess(path, data, *shared)...)
+	}
+
+	roles := map[string][]flagfile.AccessGrant{"writers": file.Writers, "readers": file.Readers, "togglers": file.Togglers}
+
+	// Temporary grants: expired ones should be removed, and ones about to
+	// lapse are flagged so nobody is surprised by losing access.
+	now := time.Now()
+	for role, grants := range roles {
+		for _, g := range grants {
+			if g.Team == "" {
+				issues = append(issues, Errorf("%s: entry with empty team", role))
+				continue
+			}
+			if g.Expires == nil {
+				continue
+			}
+			if !now.Before(*g.Expires) {
+				issues = append(issues, Errorf("%s: grant for team %q expired on %s, remove it", role, g.Team, g.Expires.Format(time.RFC3339)))
+			} else if g.Expires.Sub(now) < 7*24*time.Hour {
+				issues = append(issues, Warnf("%s: grant for team %q expires on %s", role, g.Team, g.Expires.Format(time.RFC3339)))
+			}
+		}
+	}
+
+	// Writers already hold every permission, so a writer listed under a
+	// narrower role is redundant.
+	writers := make(map[string]bool)
+	for _, g := range effective.Writers {
+		writers[g.Team] = true
+	}
+	effectiveRoles := map[string][]flagfile.AccessGrant{"readers": effective.Readers, "togglers": effective.Togglers}
+	for _, role := range []string{"readers", "togglers"} {
+		for _, g := range effectiveRoles[role] {
+			if writers[g.Team] {
+				issues = append(issues, Warnf("team %q is listed in both writers and %s", g.Team, role))
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Message < issues[j].Message })
+
+	return issues
+}
+
+// checkSharedAccess reports where an environment's access.yml diverges from
+// (or needlessly repeats) the shared definition it inherits from.
+func checkSharedAccess(path string, data []byte, shared flagfile.Access) []Issue {
+	var keys map[string]any
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil
+	}
+
+	var file flagfile.Access
+	yaml.Unmarshal(data, &file)
+
+	sharedName := filepath.Join("access", filepath.Base(filepath.Dir(path))+".yml")
+
+	var issues []Issue
+	for _, role := range []struct {
+		key           string
+		local, shared []flagfile.AccessGrant
+	}{
+		{"writers", file.Writers, shared.Writers},
+		{"readers", file.Readers, shared.Readers},
+		{"togglers", file.Togglers, shared.Togglers},
+	} {
+		if _, ok := keys[role.key]; !ok {
+			continue
+		}
+		if reflect.DeepEqual(role.local, role.shared) {
+			issues = append(issues, Warnf("%s: repeats %s, remove it to inherit the shared list", role.key, sharedName))
+		} else {
+			issues = append(issues, Warnf("%s: diverges from %s", role.key, sharedName))
+		}
+	}
+
+	return issues
+}
+
+// readSharedAccess returns the shared flags/access/<namespace>.yml that the
+// access.yml at path inherits from, or nil if there isn't a (valid) one.
+func readSharedAccess(flagsDir, path string) *flagfile.Access {
+	data, err := os.ReadFile(flagfile.SharedAccessPath(flagsDir, filepath.Dir(path)))
+	if err != nil {
+		return nil
+	}
+
+	var shared flagfile.Access
+	if err := yaml.Unmarshal(data, &shared); err != nil {
+		return nil
+	}
+
+	return &shared
+}
+
+// ---------------------------------------------------------------------------
+// ACLConfig — validates flags/acl-config.yml
+// ---------------------------------------------------------------------------
+
+func ACLConfig(path string, data []byte) []Issue {
+	var file acl.Config
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return []Issue{Errorf("invalid YAML: %v", err)}
+	}
+
+	var issues []Issue
+
+	if len(file.AdminTeams) == 0 {
+		issues = append(issues, Errorf("missing required field: adminTeams"))
+	}
+
+	// An alias claimed by two environments would make canonicalisation
+	// depend on map order.
+	canonicals := make([]string, 0, len(file.EnvironmentAliases))
+	for canonical := range file.EnvironmentAliases {
+		canonicals = append(canonicals, canonical)
+	}
+	sort.Strings(canonicals)
+
+	owner := make(map[string]string)
+	for _, canonical := range canonicals {
+		for _, name := range append([]string{canonical}, file.EnvironmentAliases[canonical]...) {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if existing, ok := owner[name]; ok && existing != canonical {
+				issues = append(issues, Errorf("environmentAliases: %q is an alias of both %q and %q", name, existing, canonical))
+				continue
+			}
+			owner[name] = canonical
+		}
+	}
+
+	if len(file.ReadOnlyEnvironments) == 0 {
+		issues = append(issues, Warnf("readOnlyEnvironments is empty, every environment is writable through Flipt"))
+	}
+
+	flagsDir := filepath.Dir(path)
+	for _, environment := range file.ReadOnlyEnvironments {
+		if _, err := os.Stat(filepath.Join(flagsDir, environment)); err != nil {
+			issues = append(issues, Warnf("readOnlyEnvironments: %q has no flags/%s directory", environment, environment))
+		}
+	}
+
+	return issues
+}
+
+// ---------------------------------------------------------------------------
+// Segment — validates a shared segment in flags/segments/
+// ---------------------------------------------------------------------------
+
+// Segment checks a shared segment definition. flagctl segments sync inlines
+// these into every namespace that references them, so they're validated once
+// here rather than in each copy.
+func Segment(path string, data []byte) []Issue {
+	var seg flagfile.Segment
+	if err := yaml.Unmarshal(data, &seg); err != nil {
+		return []Issue{Errorf("invalid YAML: %v", err)}
+	}
+
+	var issues []Issue
+
+	if seg.Key == "" {
+		issues = append(issues, Errorf("missing required field: key"))
+	} else if name := strings.TrimSuffix(filepath.Base(path), ".yml"); seg.Key != name {
+		issues = append(issues, Errorf("key %q doesn't match the file name (expected %q)", seg.Key, name))
+	}
+	if seg.Name == "" {
+		issues = append(issues, Errorf("missing required field: name"))
+	}
+
+	if seg.MatchType != "ALL_MATCH_TYPE" && seg.MatchType != "ANY_MATCH_TYPE" {
+		issues = append(issues, Errorf("invalid match_type %q (must be ALL_MATCH_TYPE or ANY_MATCH_TYPE)", seg.MatchType))
+	}
+
+	if len(seg.Constraints) == 0 {
+		issues = append(issues, Warnf("segment has no constraints, so it matches everyone"))
+	}
+	for i, c := range seg.Constraints {
+		if c.Type == "" || c.Property == "" || c.Operator == "" {
+			issues = append(issues, Errorf("constraint %d: type, property and operator are required", i+1))
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,319 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ministryofjustice/hmpps-feature-flags/flipt/scripts/flagfile"
+)
+
+// messages returns the messages of issues at level, in order.
+func messages(issues []Issue, level Level) []string {
+	var out []string
+	for _, iss := range issues {
+		if iss.Level == level {
+			out = append(out, iss.Message)
+		}
+	}
+	return out
+}
+
+func checkIssues(t *testing.T, issues []Issue, errors, warnings []string) {
+	t.Helper()
+
+	if got := messages(issues, Error); !slices.Equal(got, errors) {
+		t.Errorf("errors:\n got: %q\nwant: %q", got, errors)
+	}
+	if got := messages(issues, Warning); !slices.Equal(got, warnings) {
+		t.Errorf("warnings:\n got: %q\nwant: %q", got, warnings)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "valid",
+			in: `namespace: {key: a, name: A}
+flags:
+  - {key: f, name: F, type: BOOLEAN_FLAG_TYPE, rollouts: [{segment: {key: s, value: true}}]}
+segments:
+  - {key: s, name: S, match_type: ALL_MATCH_TYPE}
+`,
+		},
+		{
+			name:   "missing namespace",
+			in:     "flags: []\n",
+			errors: []string{"missing required field: namespace.key", "missing required field: namespace.name"},
+		},
+		{
+			name: "flag problems",
+			in: `namespace: {key: a, name: A}
+flags:
+  - {key: f, name: F, type: BOOLEAN_FLAG_TYPE, variants: [{key: v}]}
+  - {key: f, type: OTHER}
+  - {name: nameless}
+`,
+			errors: []string{
+				`flag "f": boolean flag cannot have variants`,
+				`flag "f": missing required field: name`,
+				`flag "f": duplicate key`,
+				`flag "f": invalid type "OTHER" (must be BOOLEAN_FLAG_TYPE or VARIANT_FLAG_TYPE)`,
+				"flag with empty key",
+			},
+		},
+		{
+			name: "undefined references",
+			in: `namespace: {key: a, name: A}
+flags:
+  - key: f
+    name: F
+    type: VARIANT_FLAG_TYPE
+    variants: [{key: v}]
+    rules: [{segment: {keys: [s, t]}, distributions: [{variant: w, rollout: 100}]}]
+segments:
+  - {key: s, name: S, match_type: ALL_MATCH_TYPE}
+`,
+			errors: []string{
+				`flag "f": references segment "t" which is not defined`,
+				`flag "f": distribution references variant "w" which is not defined`,
+			},
+		},
+		{
+			name: "variant problems",
+			in: `namespace: {key: a, name: A}
+flags:
+  - {key: f, name: F, type: VARIANT_FLAG_TYPE, variants: [{key: v}, {key: v}, {key: bad key}]}
+`,
+			errors: []string{
+				`flag "f": duplicate variant "v"`,
+				`flag "f": invalid variant key "bad key"`,
+			},
+		},
+		{
+			name: "unused and duplicate segments",
+			in: `namespace: {key: a, name: A}
+segments:
+  - {key: s, name: S, match_type: ALL_MATCH_TYPE}
+  - {key: s, name: S, match_type: ALL_MATCH_TYPE}
+  - {key: u, name: U, match_type: ALL_MATCH_TYPE}
+`,
+			errors:   []string{`segment "s": duplicate key`},
+			warnings: []string{`segment "u" is defined but not referenced by any flag`},
+		},
+		{
+			name:   "invalid YAML",
+			in:     "namespace: [\n",
+			errors: []string{"invalid YAML: yaml: line 1: did not find expected node content"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkIssues(t, Features([]byte(tt.in)), tt.errors, tt.warnings)
+		})
+	}
+}
+
+func TestAccess(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		in       string
+		shared   *flagfile.Access
+		errors   []string
+		warnings []string
+	}{
+		{name: "valid", in: "writers:\n    - team-a\n"},
+		{name: "no writers", in: "readers:\n    - team-a\n", errors: []string{"missing required field: writers"}},
+		{
+			name:   "expired grant",
+			in:     "writers:\n    - team-a\n    - team: team-b\n      expires: 2020-01-01T00:00:00Z\n",
+			errors: []string{`writers: grant for team "team-b" expired on 2020-01-01T00:00:00Z, remove it`},
+		},
+		{
+			name:     "expiring grant",
+			in:       "writers:\n    - team-a\n    - team: team-b\n      expires: " + soon + "\n",
+			warnings: []string{`writers: grant for team "team-b" expires on ` + soon},
+		},
+		{
+			name:     "writer in a narrower role",
+			in:       "writers:\n    - team-a\ntogglers:\n    - team-a\n",
+			warnings: []string{`team "team-a" is listed in both writers and togglers`},
+		},
+		{
+			name:   "inherits writers",
+			in:     "readers:\n    - team-b\n",
+			shared: &flagfile.Access{Writers: []flagfile.AccessGrant{{Team: "team-a"}}},
+			warnings: []string{
+				"readers: diverges from access/my-service.yml",
+			},
+		},
+		{
+			name:     "repeats shared list",
+			in:       "writers:\n    - team-a\n",
+			shared:   &flagfile.Access{Writers: []flagfile.AccessGrant{{Team: "team-a"}}},
+			warnings: []string{"writers: repeats access/my-service.yml, remove it to inherit the shared list"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Access(filepath.Join("flags", "dev", "my-service", "access.yml"), []byte(tt.in), tt.shared)
+			checkIssues(t, issues, tt.errors, tt.warnings)
+		})
+	}
+}
+
+func TestACLConfig(t *testing.T) {
+	flagsDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(flagsDir, "prod"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		in       string
+		errors   []string
+		warnings []string
+	}{
+		{name: "valid", in: "adminTeams: [admins]\nreadOnlyEnvironments: [prod]\n"},
+		{
+			name:     "empty",
+			in:       "{}\n",
+			errors:   []string{"missing required field: adminTeams"},
+			warnings: []string{"readOnlyEnvironments is empty, every environment is writable through Flipt"},
+		},
+		{
+			name:   "alias claimed twice",
+			in:     "adminTeams: [admins]\nenvironmentAliases:\n    preprod: [staging]\n    prod: [Staging]\nreadOnlyEnvironments: [prod]\n",
+			errors: []string{`environmentAliases: "staging" is an alias of both "preprod" and "prod"`},
+		},
+		{
+			name:     "read-only environment missing",
+			in:       "adminTeams: [admins]\nreadOnlyEnvironments: [live]\n",
+			warnings: []string{`readOnlyEnvironments: "live" has no flags/live directory`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkIssues(t, ACLConfig(filepath.Join(flagsDir, "acl-config.yml"), []byte(tt.in)), tt.errors, tt.warnings)
+		})
+	}
+}
+
+func TestSegment(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "valid",
+			in:   "key: test-users\nname: Test users\nconstraints:\n    - {type: STRING_COMPARISON_TYPE, property: username, operator: eq, value: a}\nmatch_type: ALL_MATCH_TYPE\n",
+		},
+		{
+			name:     "wrong key, no constraints",
+			in:       "key: other\nname: Other\nmatch_type: ALL_MATCH_TYPE\n",
+			errors:   []string{`key "other" doesn't match the file name (expected "test-users")`},
+			warnings: []string{"segment has no constraints, so it matches everyone"},
+		},
+		{
+			name: "incomplete",
+			in:   "constraints:\n    - {type: STRING_COMPARISON_TYPE}\n",
+			errors: []string{
+				"missing required field: key",
+				"missing required field: name",
+				`invalid match_type "" (must be ALL_MATCH_TYPE or ANY_MATCH_TYPE)`,
+				"constraint 1: type, property and operator are required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkIssues(t, Segment(filepath.Join("flags", "segments", "test-users.yml"), []byte(tt.in)), tt.errors, tt.warnings)
+		})
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		order  *flagfile.KeyOrder
+		errors []string
+	}{
+		{name: "canonical", in: "namespace:\n    key: a\n    name: A\n", order: flagfile.FeaturesOrder},
+		{
+			name:   "indent",
+			in:     "namespace:\n  key: a\n  name: A\n",
+			errors: []string{"formatting: line 2 differs from canonical form", "formatting: line 3 differs from canonical form"},
+		},
+		{
+			name:   "extra blank line",
+			in:     "writers:\n\n    - team-a\n",
+			errors: []string{"formatting: line 2 is an extra blank line"},
+		},
+		{
+			name:   "key order",
+			in:     "namespace:\n    name: A\n    key: a\n",
+			order:  flagfile.FeaturesOrder,
+			errors: []string{`formatting: line 3: "key" should come before "name"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkIssues(t, Formatting([]byte(tt.in), tt.order), tt.errors, nil)
+		})
+	}
+}
+
+// TestFlags lints the real flags/ tree, which CI keeps free of errors.
+func TestContent(t *testing.T) {
+	const features = "namespace:\n    key: access\n    name: Access\nflags: []\n"
+
+	tests := []struct {
+		name   string
+		path   string
+		in     string
+		errors []string
+	}{
+		{name: "shared access file", path: "flags/access/my-service.yml", in: "readers:\n    - team-a\n", errors: []string{"missing required field: writers"}},
+		{name: "namespace named access", path: "flags/dev/access/features.yml", in: features},
+		{name: "namespace access file", path: "flags/dev/access/access.yml", in: "writers:\n    - team-a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Content("flags", filepath.FromSlash(tt.path), []byte(tt.in))
+			checkIssues(t, issues, tt.errors, nil)
+		})
+	}
+}
+
+func TestFlags(t *testing.T) {
+	flagsDir := filepath.Join("..", "..", "..", "flags")
+
+	files := DiscoverFiles(flagsDir)
+	if len(files) == 0 {
+		t.Fatalf("no flag files found under %s", flagsDir)
+	}
+
+	for i, issues := range Files(flagsDir, files, 4) {
+		if errors := messages(issues, Error); len(errors) > 0 {
+			t.Errorf("%s: %q", files[i], errors)
+		}
+	}
+}